@@ -0,0 +1,171 @@
+package procwatch
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/ghetzel/go-stockutil/log"
+)
+
+// An AuditEntry records a single control action taken through the API.
+type AuditEntry struct {
+	Timestamp  time.Time    `json:"timestamp"`
+	RemoteAddr string       `json:"remote_addr,omitempty"`
+	Principal  string       `json:"principal,omitempty"`
+	Action     string       `json:"action"`
+	Program    string       `json:"program,omitempty"`
+	Group      string       `json:"group,omitempty"`
+	State      ProgramState `json:"state,omitempty"`
+	Request    string       `json:"request,omitempty"`
+	Error      string       `json:"error,omitempty"`
+}
+
+var auditLock sync.Mutex
+
+// Returns an entry recording that the given action was taken on a program, along with the
+// state the program was left in.
+func programAuditEntry(action string, program *Program, err error) *AuditEntry {
+	return &AuditEntry{
+		Action:  action,
+		Program: program.FullName(),
+		Group:   program.Group,
+		State:   program.GetState(),
+		Error:   errorString(err),
+	}
+}
+
+// Returns an entry recording that a start (or restart) was requested, along with why the
+// program couldn't be started, if it couldn't.
+func startAuditEntry(action string, program *Program) *AuditEntry {
+	var entry = programAuditEntry(action, program, nil)
+
+	if program.InState(ProgramBackoff, ProgramFatal) {
+		entry.Error = program.SpawnError
+	}
+
+	return entry
+}
+
+func errorString(err error) string {
+	if err != nil {
+		return err.Error()
+	}
+
+	return ``
+}
+
+// Appends an entry to the audit log (if one is configured), filling in the time of the request
+// and who made it.
+func (server *Server) audit(req *http.Request, entry *AuditEntry) {
+	if server.AuditLog == `` {
+		return
+	}
+
+	entry.Timestamp = time.Now()
+	entry.RemoteAddr = req.RemoteAddr
+
+	if principal, ok := RequestPrincipal(req); ok {
+		entry.Principal = principal.Name
+	}
+
+	if err := AppendAuditLog(server.AuditLog, entry); err != nil {
+		log.Errorf("audit: %v", err)
+	}
+}
+
+// Records a request that would have changed something but was turned away for lack of
+// credentials or permission.  Denied reads are not recorded.
+func (server *Server) auditDenied(req *http.Request, reason string) {
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return
+	}
+
+	server.audit(req, &AuditEntry{
+		Action:  `denied`,
+		Request: req.Method + ` ` + req.URL.Path,
+		Error:   reason,
+	})
+}
+
+// Appends an entry to the given JSON-lines audit log, creating it if it doesn't exist.
+func AppendAuditLog(filename string, entry *AuditEntry) error {
+	var line, err = json.Marshal(entry)
+
+	if err != nil {
+		return err
+	}
+
+	auditLock.Lock()
+	defer auditLock.Unlock()
+
+	// the file is opened for each entry so that rotating it out from under us is harmless
+	file, err := os.OpenFile(filename, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+
+	if err != nil {
+		return err
+	}
+
+	if _, err := file.Write(append(line, '\n')); err != nil {
+		file.Close()
+		return err
+	}
+
+	return file.Close()
+}
+
+// Reads the entries in the given audit log that were recorded between since and until.  A
+// zero time leaves that end of the range open.
+func ReadAuditLog(filename string, since time.Time, until time.Time) ([]*AuditEntry, error) {
+	var entries = make([]*AuditEntry, 0)
+	var file, err = os.Open(filename)
+
+	if errors.Is(err, os.ErrNotExist) {
+		return entries, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	defer file.Close()
+
+	var scanner = bufio.NewScanner(file)
+
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	for scanner.Scan() {
+		var entry AuditEntry
+
+		if len(scanner.Bytes()) == 0 {
+			continue
+		} else if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return nil, err
+		}
+
+		if !since.IsZero() && entry.Timestamp.Before(since) {
+			continue
+		} else if !until.IsZero() && entry.Timestamp.After(until) {
+			continue
+		}
+
+		entries = append(entries, &entry)
+	}
+
+	return entries, scanner.Err()
+}
+
+// Parses the "since" and "until" filters of the audit log endpoint, which may be given as
+// RFC 3339 timestamps or as durations (e.g.: "1h") relative to now.
+func parseAuditTime(value string) (time.Time, error) {
+	if value == `` {
+		return time.Time{}, nil
+	} else if d, err := time.ParseDuration(value); err == nil {
+		return time.Now().Add(-d), nil
+	} else {
+		return time.Parse(time.RFC3339, value)
+	}
+}
@@ -0,0 +1,106 @@
+package procwatch
+
+import (
+	"context"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/hex"
+	"net/http"
+	"strings"
+)
+
+// The realm presented to browsers when asking for credentials.
+var AuthRealm = `procwatch`
+
+type principalKey struct{}
+
+// A token that can be used to access the API, defined in a [token:NAME] section.
+type APIToken struct {
+	Name  string `json:"name"  ini:"-"`
+	Token string `json:"-"     ini:"token"`
+	Role  string `json:"role"  ini:"role"`
+}
+
+// The user or token that a request was authenticated as, and the role it was granted.
+type Principal struct {
+	Name  string `json:"name"`
+	Token bool   `json:"token,omitempty"`
+	Role  string `json:"role"`
+}
+
+// Returns the principal that the given request was authenticated as, if any.
+func RequestPrincipal(req *http.Request) (*Principal, bool) {
+	principal, ok := req.Context().Value(principalKey{}).(*Principal)
+	return principal, ok
+}
+
+// Returns whether any credentials are configured, in which case all requests must be
+// authenticated.
+func (server *Server) AuthEnabled() bool {
+	return server.Username != `` || len(server.Tokens) > 0
+}
+
+// Checks a username and password against the configured ones.  The password may be given in
+// the config in plain text or as "{SHA}" followed by the hex-encoded SHA-1 hash of the password.
+func (server *Server) checkPassword(username string, password string) bool {
+	if server.Username == `` || !secureEqual(username, server.Username) {
+		return false
+	}
+
+	if hashed, ok := strings.CutPrefix(server.Password, `{SHA}`); ok {
+		var sum = sha1.Sum([]byte(password))
+		return secureEqual(hex.EncodeToString(sum[:]), strings.ToLower(hashed))
+	}
+
+	return secureEqual(password, server.Password)
+}
+
+// returns the token with the given value, if one is configured
+func (server *Server) checkToken(value string) (*APIToken, bool) {
+	for _, token := range server.Tokens {
+		if token.Token != `` && secureEqual(value, token.Token) {
+			return token, true
+		}
+	}
+
+	return nil, false
+}
+
+// Works out who the request is from.  Tokens are accepted as bearer tokens, or as the password
+// of a Basic auth login (so they can be used from the browser's login prompt too).  The
+// configured username is granted the server's "role" option, which defaults to admin.
+func (server *Server) authenticate(req *http.Request) (*Principal, bool) {
+	if bearer, ok := strings.CutPrefix(req.Header.Get(`Authorization`), `Bearer `); ok {
+		if token, ok := server.checkToken(strings.TrimSpace(bearer)); ok {
+			return &Principal{Name: token.Name, Token: true, Role: token.Role}, true
+		}
+	} else if username, password, ok := req.BasicAuth(); ok {
+		if server.checkPassword(username, password) {
+			return &Principal{Name: username, Role: server.UserRole}, true
+		} else if token, ok := server.checkToken(password); ok {
+			return &Principal{Name: token.Name, Token: true, Role: token.Role}, true
+		}
+	}
+
+	return nil, false
+}
+
+// Middleware that rejects requests without valid credentials (when authentication is enabled).
+func (server *Server) requireAuth(w http.ResponseWriter, req *http.Request, next http.HandlerFunc) {
+	if !server.AuthEnabled() {
+		next(w, req)
+	} else if principal, ok := server.authenticate(req); ok {
+		next(w, req.WithContext(context.WithValue(req.Context(), principalKey{}, principal)))
+	} else if server.PublicStatus && req.URL.Path == `/api/status` {
+		next(w, req)
+	} else {
+		// this is what makes browsers show a login prompt for the UI
+		server.auditDenied(req, `Unauthorized`)
+		w.Header().Set(`WWW-Authenticate`, `Basic realm="`+AuthRealm+`"`)
+		http.Error(w, `Unauthorized`, http.StatusUnauthorized)
+	}
+}
+
+func secureEqual(a string, b string) bool {
+	return subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
+}
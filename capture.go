@@ -0,0 +1,91 @@
+package procwatch
+
+import (
+	"strings"
+
+	"github.com/dustin/go-humanize"
+)
+
+// Output written between these puts the stream into capture mode (see outputCapture).
+const (
+	CaptureBeginToken = `<!--XSUPERVISOR:BEGIN-->`
+	CaptureEndToken   = `<!--XSUPERVISOR:END-->`
+)
+
+// Tracks capture mode for one of a process' output streams: whatever is written between
+// CaptureBeginToken and CaptureEndToken (up to maxBytes of it) is kept out of the log and
+// reported in a PROCESS_COMMUNICATION event instead.
+type outputCapture struct {
+	maxBytes  int
+	capturing bool
+	data      strings.Builder
+}
+
+// Returns a capture for the given stream, or nil if its *_capture_maxbytes isn't set.
+func (program *Program) newOutputCapture(stdout bool) *outputCapture {
+	var maxbytes = program.StderrCaptureMaxBytes
+
+	if stdout {
+		maxbytes = program.StdoutCaptureMaxBytes
+	}
+
+	if b, err := humanize.ParseBytes(maxbytes); err == nil && b > 0 {
+		return &outputCapture{
+			maxBytes: int(b),
+		}
+	}
+
+	return nil
+}
+
+// Takes a line of output, returning what remains of it to be logged (if anything) and the
+// data of any captures it completed.
+func (capture *outputCapture) filter(line string) (string, bool, []string) {
+	var logged strings.Builder
+	var completed []string
+	var rest = line
+	var marked = capture.capturing
+
+	for {
+		if capture.capturing {
+			if i := strings.Index(rest, CaptureEndToken); i >= 0 {
+				capture.write(rest[:i])
+				completed = append(completed, capture.data.String())
+				capture.data.Reset()
+				capture.capturing = false
+				rest = rest[i+len(CaptureEndToken):]
+				marked = true
+			} else {
+				// lines arrive without the newline that ended them
+				capture.write(rest + "\n")
+				break
+			}
+		} else if i := strings.Index(rest, CaptureBeginToken); i >= 0 {
+			logged.WriteString(rest[:i])
+			capture.capturing = true
+			rest = rest[i+len(CaptureBeginToken):]
+			marked = true
+		} else {
+			logged.WriteString(rest)
+			break
+		}
+	}
+
+	// a line that was only markers and captured output leaves nothing to log
+	if marked && strings.TrimSpace(logged.String()) == `` {
+		return ``, false, completed
+	}
+
+	return logged.String(), true, completed
+}
+
+// Appends to the captured data, discarding anything beyond maxBytes.
+func (capture *outputCapture) write(data string) {
+	if remaining := capture.maxBytes - capture.data.Len(); remaining <= 0 {
+		return
+	} else if len(data) > remaining {
+		data = data[:remaining]
+	}
+
+	capture.data.WriteString(data)
+}
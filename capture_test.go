@@ -0,0 +1,92 @@
+package procwatch
+
+import (
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestOutputCapture(t *testing.T) {
+	assert := require.New(t)
+	capture := &outputCapture{
+		maxBytes: 16,
+	}
+
+	line, ok, captured := capture.filter(`plain`)
+	assert.Equal(`plain`, line)
+	assert.True(ok)
+	assert.Empty(captured)
+
+	line, ok, captured = capture.filter(`one ` + CaptureBeginToken + `a` + CaptureEndToken + ` two ` + CaptureBeginToken + `b`)
+	assert.Equal(`one  two `, line)
+	assert.True(ok)
+	assert.Equal([]string{`a`}, captured)
+
+	_, ok, captured = capture.filter(`more than sixteen bytes`)
+	assert.False(ok)
+	assert.Empty(captured)
+
+	_, ok, captured = capture.filter(`ignored` + CaptureEndToken)
+	assert.False(ok)
+	assert.Equal([]string{"b\nmore than sixt"}, captured)
+
+	_, err := LoadProgramsFromConfig([]byte("[program:bad]\ncommand = true\nstdout_capture_maxbytes = lots\n"), newManagerWithDefaults())
+	assert.ErrorContains(err, `stdout_capture_maxbytes`)
+}
+
+func TestProgramCaptureMode(t *testing.T) {
+	assert := require.New(t)
+	t.Setenv(`PROCWATCH_TEST_LOGDIR`, t.TempDir())
+
+	manager, err := newManager(`capture`)
+	assert.NoError(err)
+
+	var events = make(chan *Event, 10)
+
+	manager.AddEventHandler(func(event *Event) {
+		if event.HasName(`PROCESS_COMMUNICATION`) || event.HasName(`PROCESS_LOG`) {
+			events <- event
+		}
+	})
+
+	go manager.Run()
+	time.Sleep(time.Second)
+
+	reporter, ok := manager.Program(`reporter`)
+	assert.True(ok)
+
+	var header = fmt.Sprintf("processname:reporter groupname:reporter pid:%d", reporter.PID())
+	var communications = make(map[string][]string)
+	var logged []string
+
+	for len(events) > 0 {
+		var event = <-events
+
+		if event.HasName(`PROCESS_LOG`) {
+			logged = append(logged, event.Data)
+		} else {
+			assert.Equal(header, event.Payload()[:len(header)])
+			communications[event.Name()] = append(communications[event.Name()], event.Data)
+		}
+	}
+
+	assert.Equal(map[string][]string{
+		`PROCESS_COMMUNICATION_STDOUT`: {"\nresult: 42\n", `inline`},
+		`PROCESS_COMMUNICATION_STDERR`: {`trunc`},
+	}, communications)
+
+	// captured output is kept out of the log, and out of PROCESS_LOG events
+	assert.Equal([]string{`before`, `after  done`}, logged)
+
+	data, err := os.ReadFile(reporter.LogfilePath(true))
+	assert.NoError(err)
+	assert.Contains(string(data), " before\n")
+	assert.Contains(string(data), " after  done\n")
+	assert.NotContains(string(data), `result`)
+	assert.NotContains(string(data), `XSUPERVISOR`)
+
+	stopAndVerifyManager(manager, assert)
+}
@@ -0,0 +1,114 @@
+package client
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ghetzel/procwatch"
+	"github.com/stretchr/testify/require"
+)
+
+// writes a certificate and key named for the given name to dir, signed by the CA of the given
+// name (or self-signed as a CA if none is given)
+func writeCertificate(assert *require.Assertions, dir string, name string, ca string) {
+	var key, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(err)
+
+	var template = &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		DNSNames:     []string{`localhost`},
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+
+	var parent, signer = template, any(key)
+
+	if ca == `` {
+		template.IsCA = true
+		template.BasicConstraintsValid = true
+	} else {
+		certPEM, err := os.ReadFile(filepath.Join(dir, ca+`.crt`))
+		assert.NoError(err)
+		keyPEM, err := os.ReadFile(filepath.Join(dir, ca+`.key`))
+		assert.NoError(err)
+
+		certBlock, _ := pem.Decode(certPEM)
+		keyBlock, _ := pem.Decode(keyPEM)
+
+		parent, err = x509.ParseCertificate(certBlock.Bytes)
+		assert.NoError(err)
+		signer, err = x509.ParseECPrivateKey(keyBlock.Bytes)
+		assert.NoError(err)
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, signer)
+	assert.NoError(err)
+
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	assert.NoError(err)
+
+	assert.NoError(os.WriteFile(filepath.Join(dir, name+`.crt`), pem.EncodeToMemory(&pem.Block{Type: `CERTIFICATE`, Bytes: der}), 0600))
+	assert.NoError(os.WriteFile(filepath.Join(dir, name+`.key`), pem.EncodeToMemory(&pem.Block{Type: `EC PRIVATE KEY`, Bytes: keyDer}), 0600))
+}
+
+func TestClientMutualTLS(t *testing.T) {
+	assert := require.New(t)
+	dir := t.TempDir()
+
+	writeCertificate(assert, dir, `ca`, ``)
+	writeCertificate(assert, dir, `server`, `ca`)
+	writeCertificate(assert, dir, `client`, `ca`)
+
+	server := &procwatch.Server{
+		Address:     `127.0.0.1:0`,
+		TLSCert:     filepath.Join(dir, `server.crt`),
+		TLSKey:      filepath.Join(dir, `server.key`),
+		TLSClientCA: filepath.Join(dir, `ca.crt`),
+	}
+
+	assert.NoError(server.Initialize(procwatch.NewManager()))
+	go server.Start()
+
+	// the URL has the real port once the server is listening
+	assert.Eventually(func() bool {
+		return !strings.HasSuffix(server.URL(), `:0`)
+	}, 5*time.Second, 10*time.Millisecond)
+
+	client, err := NewClient(
+		server.URL(),
+		WithCA(filepath.Join(dir, `ca.crt`)),
+		WithClientCertificate(filepath.Join(dir, `client.crt`), filepath.Join(dir, `client.key`)),
+	)
+
+	assert.NoError(err)
+	assert.NoError(client.Ping())
+
+	programs, err := client.GetPrograms()
+	assert.NoError(err)
+	assert.Empty(programs)
+
+	// the server turns away clients without a certificate
+	anonymous, err := NewClient(server.URL(), WithCA(filepath.Join(dir, `ca.crt`)))
+	assert.NoError(err)
+	assert.Error(anonymous.Ping())
+
+	// and clients that don't trust the server's CA won't talk to it
+	untrusting, err := NewClient(server.URL(), WithClientCertificate(filepath.Join(dir, `client.crt`), filepath.Join(dir, `client.key`)))
+	assert.NoError(err)
+	assert.Error(untrusting.Ping())
+}
@@ -8,14 +8,43 @@ type Program struct {
 }
 
 func (self *Program) Start() int {
-	self.client.DoProgramAction(self.Name, `start`)
+	self.client.DoProgramAction(self.FullName(), `start`)
 	return 0
 }
 
 func (self *Program) Stop() {
-	self.client.DoProgramAction(self.Name, `stop`)
+	self.client.DoProgramAction(self.FullName(), `stop`)
 }
 
 func (self *Program) Restart() {
-	self.client.DoProgramAction(self.Name, `restart`)
+	self.client.DoProgramAction(self.FullName(), `restart`)
+}
+
+func (self *Program) Signal(signal string) error {
+	return self.client.SignalProgram(self.FullName(), signal)
+}
+
+func (self *Program) WriteStdin(data []byte) error {
+	return self.client.WriteProgramStdin(self.FullName(), data)
+}
+
+type Group struct {
+	*procwatch.Group
+	client *Client
+}
+
+func (self *Group) Start() {
+	self.client.DoGroupAction(self.Name, `start`)
+}
+
+func (self *Group) Stop() {
+	self.client.DoGroupAction(self.Name, `stop`)
+}
+
+func (self *Group) Restart() {
+	self.client.DoGroupAction(self.Name, `restart`)
+}
+
+func (self *Group) Signal(signal string) error {
+	return self.client.SignalGroup(self.Name, signal)
 }
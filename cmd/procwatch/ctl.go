@@ -0,0 +1,622 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/ghetzel/cli"
+	"github.com/ghetzel/procwatch"
+	"github.com/ghetzel/procwatch/client"
+)
+
+// exit statuses, as returned by supervisorctl
+const (
+	ExitSuccess          = 0
+	ExitGeneric          = 1
+	ExitInvalidArguments = 2
+	ExitNotRunning       = 3 // status: one or more processes are not running
+	ExitUnknownProcess   = 4 // status: no such process
+	ExitServerNotRunning = 7
+)
+
+var TailPollInterval = 250 * time.Millisecond
+
+// A Controller performs supervisorctl-style operations against a remote procwatch instance.
+type Controller struct {
+	client *client.Client
+	out    io.Writer
+	json   bool
+}
+
+func NewController(c *client.Client, out io.Writer, asJson bool) *Controller {
+	return &Controller{
+		client: c,
+		out:    out,
+		json:   asJson,
+	}
+}
+
+type ctlProgramStatus struct {
+	Name        string                 `json:"name"`
+	Group       string                 `json:"group"`
+	State       procwatch.ProgramState `json:"state"`
+	StateCode   int                    `json:"statecode"`
+	PID         int                    `json:"pid,omitempty"`
+	ExitStatus  int                    `json:"exitstatus"`
+	Description string                 `json:"description"`
+}
+
+type ctlActionResult struct {
+	Name   string `json:"name"`
+	Action string `json:"action"`
+	OK     bool   `json:"ok"`
+	Error  string `json:"error,omitempty"`
+}
+
+func (ctl *Controller) printf(format string, args ...any) {
+	if !ctl.json {
+		fmt.Fprintf(ctl.out, format, args...)
+	}
+}
+
+func (ctl *Controller) printJSON(value any) {
+	if ctl.json {
+		var enc = json.NewEncoder(ctl.out)
+		enc.SetIndent(``, `  `)
+		enc.Encode(value)
+	}
+}
+
+// prints an error about the named program (or server), as a single-line result object if
+// output is JSON
+func (ctl *Controller) printError(name string, action string, err error) {
+	if ctl.json {
+		json.NewEncoder(ctl.out).Encode(ctlActionResult{Name: name, Action: action, Error: err.Error()})
+	} else {
+		fmt.Fprintf(ctl.out, "%s: ERROR (%v)\n", name, err)
+	}
+}
+
+// Returns the programs the given name refers to: "all", a program, a group (optionally
+// given as "group:*"), or every instance of a program.
+func resolvePrograms(programs []*client.Program, name string) []*client.Program {
+	var matches = make([]*client.Program, 0)
+
+	if name == `all` {
+		return programs
+	}
+
+	for _, program := range programs {
+		if program.FullName() == name || program.Name == name {
+			return []*client.Program{program}
+		}
+	}
+
+	var group = strings.TrimSuffix(name, `:*`)
+
+	for _, program := range programs {
+		if program.Group == group {
+			matches = append(matches, program)
+		}
+	}
+
+	if len(matches) == 0 {
+		for _, program := range programs {
+			if program.ProgramName == name {
+				matches = append(matches, program)
+			}
+		}
+	}
+
+	return matches
+}
+
+// checks that the server is reachable, printing an error if it isn't
+func (ctl *Controller) upcheck() int {
+	if err := ctl.client.Ping(); errors.Is(err, client.ErrUnauthorized) {
+		fmt.Fprintf(os.Stderr, "Server requires authentication\n")
+		return ExitGeneric
+	} else if err != nil {
+		fmt.Fprintf(os.Stderr, "%s refused connection: %v\n", ctl.client.Address(), err)
+		return ExitServerNotRunning
+	}
+
+	return ExitSuccess
+}
+
+// Prints the status of the named programs (or all of them).
+func (ctl *Controller) Status(names []string) int {
+	if code := ctl.upcheck(); code != ExitSuccess {
+		return code
+	}
+
+	var exitCode = ExitSuccess
+	var programs, err = ctl.client.GetPrograms()
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return ExitGeneric
+	}
+
+	var matched = make([]*client.Program, 0)
+
+	if len(names) == 0 {
+		matched = programs
+	}
+
+	for _, name := range names {
+		if m := resolvePrograms(programs, name); len(m) > 0 {
+			matched = append(matched, m...)
+		} else {
+			ctl.printf("%s: ERROR (no such process)\n", name)
+			exitCode = ExitUnknownProcess
+		}
+	}
+
+	var width = 30
+	var statuses = make([]ctlProgramStatus, 0)
+
+	for _, program := range matched {
+		if n := len(program.FullName()); n > width {
+			width = n
+		}
+	}
+
+	for _, program := range matched {
+		var state = program.GetState()
+
+		statuses = append(statuses, ctlProgramStatus{
+			Name:        program.FullName(),
+			Group:       program.Group,
+			State:       state,
+			StateCode:   state.Code(),
+			PID:         max(program.PID(), 0),
+			ExitStatus:  program.LastExitStatus,
+			Description: program.String(),
+		})
+
+		ctl.printf("%-*s %-10s %s\n", width, program.FullName(), state, program.String())
+
+		if state != procwatch.ProgramRunning && exitCode == ExitSuccess {
+			exitCode = ExitNotRunning
+		}
+	}
+
+	ctl.printJSON(statuses)
+	return exitCode
+}
+
+// Performs the given action ("start", "stop", or "restart") on the named programs.
+func (ctl *Controller) Action(action string, names []string) int {
+	if len(names) == 0 {
+		fmt.Fprintf(os.Stderr, "error: %s requires a process name\n", action)
+		return ExitInvalidArguments
+	}
+
+	if code := ctl.upcheck(); code != ExitSuccess {
+		return code
+	}
+
+	var exitCode = ExitSuccess
+	var programs, err = ctl.client.GetPrograms()
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return ExitGeneric
+	}
+
+	var results = make([]ctlActionResult, 0)
+
+	for _, name := range names {
+		var matched = resolvePrograms(programs, name)
+
+		if len(matched) == 0 {
+			ctl.printf("%s: ERROR (no such process)\n", name)
+			results = append(results, ctlActionResult{Name: name, Action: action, Error: `no such process`})
+			exitCode = ExitGeneric
+			continue
+		}
+
+		for _, program := range matched {
+			var result = ctlActionResult{
+				Name:   program.FullName(),
+				Action: action,
+			}
+
+			if err := ctl.programAction(program, action); err == nil {
+				result.OK = true
+			} else {
+				result.Error = err.Error()
+				ctl.printf("%s: ERROR (%v)\n", program.FullName(), err)
+				exitCode = ExitGeneric
+			}
+
+			results = append(results, result)
+		}
+	}
+
+	ctl.printJSON(results)
+	return exitCode
+}
+
+func (ctl *Controller) programAction(program *client.Program, action string) error {
+	var running = program.InState(procwatch.ProgramStarting, procwatch.ProgramRunning)
+
+	switch action {
+	case `start`:
+		if running {
+			return fmt.Errorf("already started")
+		}
+
+		return ctl.start(program.FullName())
+
+	case `stop`:
+		if !running {
+			return fmt.Errorf("not running")
+		}
+
+		return ctl.stop(program.FullName())
+
+	case `restart`:
+		if running {
+			if err := ctl.stop(program.FullName()); err != nil {
+				return err
+			}
+		}
+
+		return ctl.start(program.FullName())
+
+	default:
+		return fmt.Errorf("unknown action %q", action)
+	}
+}
+
+func (ctl *Controller) start(name string) error {
+	if err := ctl.client.DoProgramAction(name, `start`); err != nil {
+		return err
+	}
+
+	// the server returns once the program has started (or failed to)
+	if current, err := ctl.client.GetProgram(name); err != nil {
+		return err
+	} else if state := current.GetState(); state != procwatch.ProgramRunning {
+		return fmt.Errorf("spawn error, state is %v", state)
+	}
+
+	ctl.printf("%s: started\n", name)
+	return nil
+}
+
+func (ctl *Controller) stop(name string) error {
+	if err := ctl.client.DoProgramAction(name, `stop`); err != nil {
+		return err
+	}
+
+	if current, err := ctl.client.GetProgram(name); err != nil {
+		return err
+	} else if current.InState(procwatch.ProgramStarting, procwatch.ProgramRunning) {
+		return fmt.Errorf("failed to stop")
+	}
+
+	ctl.printf("%s: stopped\n", name)
+	return nil
+}
+
+// Sends a signal to the named programs.
+func (ctl *Controller) Signal(signal string, names []string) int {
+	if signal == `` || len(names) == 0 {
+		fmt.Fprintf(os.Stderr, "error: signal requires a signal name and a process name\n")
+		return ExitInvalidArguments
+	} else if _, err := procwatch.ParseProgramSignal(signal); err != nil {
+		fmt.Fprintf(os.Stderr, "error: bad signal name %q\n", signal)
+		return ExitInvalidArguments
+	}
+
+	if code := ctl.upcheck(); code != ExitSuccess {
+		return code
+	}
+
+	var exitCode = ExitSuccess
+	var programs, err = ctl.client.GetPrograms()
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return ExitGeneric
+	}
+
+	var results = make([]ctlActionResult, 0)
+
+	for _, name := range names {
+		var matched = resolvePrograms(programs, name)
+
+		if len(matched) == 0 {
+			ctl.printf("%s: ERROR (no such process)\n", name)
+			results = append(results, ctlActionResult{Name: name, Action: `signal`, Error: `no such process`})
+			exitCode = ExitGeneric
+			continue
+		}
+
+		for _, program := range matched {
+			var result = ctlActionResult{
+				Name:   program.FullName(),
+				Action: `signal`,
+			}
+
+			if _, err := ctl.client.CallRPC(`supervisor.signalProcess`, program.FullName(), signal); err == nil {
+				result.OK = true
+				ctl.printf("%s: signalled\n", program.FullName())
+			} else {
+				result.Error = err.Error()
+				ctl.printf("%s: ERROR (%v)\n", program.FullName(), err)
+				exitCode = ExitGeneric
+			}
+
+			results = append(results, result)
+		}
+	}
+
+	ctl.printJSON(results)
+	return exitCode
+}
+
+// Rereads the server's configuration file and applies the changes.  If dryRun is set, the
+// groups that would change are only reported.
+func (ctl *Controller) Reload(dryRun bool) int {
+	if code := ctl.upcheck(); code != ExitSuccess {
+		return code
+	}
+
+	if diff, err := ctl.client.Reload(dryRun); err == nil {
+		var verbs = []string{`added process group`, `updated process group`, `removed process group`}
+
+		if dryRun {
+			verbs = []string{`available`, `changed`, `disappeared`}
+		}
+
+		for i, names := range [][]string{diff.Added, diff.Changed, diff.Removed} {
+			for _, name := range names {
+				ctl.printf("%s: %s\n", name, verbs[i])
+			}
+		}
+
+		if len(diff.Added)+len(diff.Changed)+len(diff.Removed) == 0 {
+			ctl.printf("No config updates to processes\n")
+		}
+
+		ctl.printJSON(diff)
+		return ExitSuccess
+	} else {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return ExitGeneric
+	}
+}
+
+// Asks the server to stop all programs and exit.
+func (ctl *Controller) Shutdown() int {
+	if code := ctl.upcheck(); code != ExitSuccess {
+		return code
+	}
+
+	if err := ctl.client.Shutdown(); err == nil {
+		ctl.printf("Shut down\n")
+		ctl.printJSON(ctlActionResult{Name: ctl.client.Address(), Action: `shutdown`, OK: true})
+		return ExitSuccess
+	} else {
+		ctl.printError(ctl.client.Address(), `shutdown`, err)
+		return ExitGeneric
+	}
+}
+
+// Writes everything read from input to the stdin of the named program.
+func (ctl *Controller) Stdin(name string, input io.Reader) int {
+	if name == `` {
+		fmt.Fprintf(os.Stderr, "error: stdin requires a process name\n")
+		return ExitInvalidArguments
+	}
+
+	if code := ctl.upcheck(); code != ExitSuccess {
+		return code
+	}
+
+	if data, err := io.ReadAll(input); err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return ExitGeneric
+	} else if err := ctl.client.WriteProgramStdin(name, data); err != nil {
+		ctl.printError(name, `stdin`, err)
+		return ExitGeneric
+	}
+
+	ctl.printJSON(ctlActionResult{Name: name, Action: `stdin`, OK: true})
+	return ExitSuccess
+}
+
+// Prints the end of a program's stdout or stderr log.  If follow is set, new output is
+// printed as it is written until the context is cancelled.
+func (ctl *Controller) Tail(ctx context.Context, name string, stream string, follow bool) int {
+	if name == `` {
+		fmt.Fprintf(os.Stderr, "error: tail requires a process name\n")
+		return ExitInvalidArguments
+	}
+
+	switch stream {
+	case ``:
+		stream = `stdout`
+	case `stdout`, `stderr`:
+	default:
+		fmt.Fprintf(os.Stderr, "error: stream must be one of stdout or stderr\n")
+		return ExitInvalidArguments
+	}
+
+	if code := ctl.upcheck(); code != ExitSuccess {
+		return code
+	}
+
+	var chunk, err = ctl.client.ReadProgramLog(name, stream, -procwatch.DefaultLogTailBytes, 0)
+
+	if err != nil {
+		ctl.printError(name, `tail`, err)
+		return ExitGeneric
+	}
+
+	ctl.printTail(chunk)
+
+	for follow {
+		select {
+		case <-ctx.Done():
+			return ExitSuccess
+		case <-time.After(TailPollInterval):
+		}
+
+		if next, err := ctl.client.ReadProgramLog(name, stream, chunk.Offset, 0); err == nil {
+			// the log was rotated or truncated; start again from the top
+			if next.Size < chunk.Offset {
+				if next, err = ctl.client.ReadProgramLog(name, stream, 0, 0); err != nil {
+					ctl.printError(name, `tail`, err)
+					return ExitGeneric
+				}
+			}
+
+			chunk = next
+		} else {
+			ctl.printError(name, `tail`, err)
+			return ExitGeneric
+		}
+
+		ctl.printTail(chunk)
+	}
+
+	return ExitSuccess
+}
+
+func (ctl *Controller) printTail(chunk *procwatch.LogChunk) {
+	if ctl.json {
+		json.NewEncoder(ctl.out).Encode(chunk)
+	} else {
+		fmt.Fprint(ctl.out, chunk.Data)
+	}
+}
+
+func ctlCommands() []cli.Command {
+	var jsonFlag = cli.BoolFlag{
+		Name:  `json, j`,
+		Usage: `Output results as JSON`,
+	}
+
+	var controller = func(c *cli.Context) *Controller {
+		if client, err := newClient(c); err == nil {
+			return NewController(client, os.Stdout, c.Bool(`json`))
+		} else {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			os.Exit(ExitGeneric)
+			return nil
+		}
+	}
+
+	var action = func(name string) func(c *cli.Context) {
+		return func(c *cli.Context) {
+			os.Exit(controller(c).Action(name, c.Args()))
+		}
+	}
+
+	return []cli.Command{
+		{
+			Name:      `status`,
+			Usage:     `Show the status of programs`,
+			ArgsUsage: `[NAME ..]`,
+			Flags:     []cli.Flag{jsonFlag},
+			Action: func(c *cli.Context) {
+				os.Exit(controller(c).Status(c.Args()))
+			},
+		}, {
+			Name:      `start`,
+			Usage:     `Start programs`,
+			ArgsUsage: `<NAME|GROUP:*|all> ..`,
+			Flags:     []cli.Flag{jsonFlag},
+			Action:    action(`start`),
+		}, {
+			Name:      `stop`,
+			Usage:     `Stop programs`,
+			ArgsUsage: `<NAME|GROUP:*|all> ..`,
+			Flags:     []cli.Flag{jsonFlag},
+			Action:    action(`stop`),
+		}, {
+			Name:      `restart`,
+			Usage:     `Restart programs`,
+			ArgsUsage: `<NAME|GROUP:*|all> ..`,
+			Flags:     []cli.Flag{jsonFlag},
+			Action:    action(`restart`),
+		}, {
+			Name:      `signal`,
+			Usage:     `Send a signal to programs`,
+			ArgsUsage: `<SIGNAL> <NAME|GROUP:*|all> ..`,
+			Flags:     []cli.Flag{jsonFlag},
+			Action: func(c *cli.Context) {
+				os.Exit(controller(c).Signal(c.Args().First(), c.Args().Tail()))
+			},
+		}, {
+			Name:  `reread`,
+			Usage: `Reload the configuration file and show which groups would change`,
+			Flags: []cli.Flag{jsonFlag},
+			Action: func(c *cli.Context) {
+				os.Exit(controller(c).Reload(true))
+			},
+		}, {
+			Name:  `update`,
+			Usage: `Reload the configuration file, adding, removing and restarting groups as needed`,
+			Flags: []cli.Flag{jsonFlag},
+			Action: func(c *cli.Context) {
+				os.Exit(controller(c).Reload(false))
+			},
+		}, {
+			Name:  `shutdown`,
+			Usage: `Stop all programs and shut down procwatch`,
+			Flags: []cli.Flag{jsonFlag},
+			Action: func(c *cli.Context) {
+				os.Exit(controller(c).Shutdown())
+			},
+		}, {
+			Name:  `shell`,
+			Usage: `Start an interactive shell for controlling programs`,
+			Action: func(c *cli.Context) {
+				if err := NewShell(controller(c)).Run(); err != nil {
+					fmt.Fprintf(os.Stderr, "error: %v\n", err)
+					os.Exit(ExitGeneric)
+				}
+			},
+		}, {
+			Name:      `stdin`,
+			Usage:     `Write a line of text (or, if none is given, this command's stdin) to a program's stdin`,
+			ArgsUsage: `<NAME> [TEXT..]`,
+			Flags:     []cli.Flag{jsonFlag},
+			Action: func(c *cli.Context) {
+				var input io.Reader = os.Stdin
+
+				if text := c.Args().Tail(); len(text) > 0 {
+					input = strings.NewReader(strings.Join(text, ` `) + "\n")
+				}
+
+				os.Exit(controller(c).Stdin(c.Args().First(), input))
+			},
+		}, {
+			Name:      `tail`,
+			Usage:     `Show the output of a program`,
+			ArgsUsage: `<NAME> [stdout|stderr]`,
+			Flags: []cli.Flag{
+				jsonFlag,
+				cli.BoolFlag{
+					Name:  `follow, f`,
+					Usage: `Continue printing output as it is written`,
+				},
+			},
+			Action: func(c *cli.Context) {
+				os.Exit(controller(c).Tail(context.Background(), c.Args().First(), c.Args().Get(1), c.Bool(`follow`)))
+			},
+		},
+	}
+}
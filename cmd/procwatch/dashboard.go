@@ -73,21 +73,15 @@ type Dashboard struct {
 	sysinfoData       *maputil.Map
 }
 
-func NewDashboard(url string) (*Dashboard, error) {
-	var dash = &Dashboard{
+func NewDashboard(c *client.Client) *Dashboard {
+	return &Dashboard{
 		lines:           make([]string, 0),
 		lineBuffer:      bytes.NewBuffer(nil),
 		guictx:          context.Background(),
 		targetFPS:       8,
 		sysinfo:         sysfact.NewReporter(),
 		sysinfoInterval: time.Second,
-	}
-
-	if c, err := client.NewClient(url); err == nil {
-		dash.client = c
-		return dash, nil
-	} else {
-		return nil, err
+		client:          c,
 	}
 }
 
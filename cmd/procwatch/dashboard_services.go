@@ -38,8 +38,10 @@ func (self *ServicesDashboardPage) getSelectedProgram() (*client.Program, bool)
 	var row, _ = self.table.GetSelection()
 
 	if namecell := self.table.GetCell(row, 0); namecell != nil {
-		if p, err := self.dash.client.GetProgram(namecell.Text); err == nil {
-			return p, true
+		if name, ok := namecell.GetReference().(string); ok {
+			if p, err := self.dash.client.GetProgram(name); err == nil {
+				return p, true
+			}
 		}
 	}
 
@@ -57,6 +59,9 @@ func (self *ServicesDashboardPage) HandleKeyEvent(event *tcell.EventKey) *tcell.
 			go program.Start()
 		case tcell.KeyCtrlK:
 			go program.Stop()
+		case tcell.KeyCtrlU:
+			// most daemons reload their configuration on SIGHUP
+			go program.Signal(string(procwatch.SIGHUP))
 		}
 	}
 
@@ -88,7 +93,7 @@ func (self *ServicesDashboardPage) Update() error {
 	}
 
 	for _, program := range programs {
-		if l := len(program.Name); l > maxNameLen {
+		if l := len(program.FullName()); l > maxNameLen {
 			maxNameLen = l
 		}
 		if l := len(program.Schedule); l > maxScheduleLen {
@@ -118,8 +123,9 @@ func (self *ServicesDashboardPage) Update() error {
 		var cells = make([]*tview.TableCell, 5)
 		//
 		// ---------------------------------------------------------------------
-		cells[0] = tview.NewTableCell(fmt.Sprintf(fmtName, program.Name))
+		cells[0] = tview.NewTableCell(fmt.Sprintf(fmtName, program.FullName()))
 		cells[0].SetMaxWidth(maxNameLen + rpad)
+		cells[0].SetReference(program.FullName())
 		//
 		// ---------------------------------------------------------------------
 		var statefmt = "[%s::]%- 10s"
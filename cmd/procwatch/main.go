@@ -5,6 +5,7 @@ import (
 	"os"
 	"os/signal"
 	"os/user"
+	"syscall"
 	"time"
 
 	"github.com/ghetzel/cli"
@@ -42,8 +43,38 @@ func main() {
 		},
 		cli.StringFlag{
 			Name:   `client-address, a`,
-			Usage:  `The address to connect to for client operations`,
-			EnvVar: client.DefaultClientAddress,
+			Usage:  `The address to connect to for client operations (an HTTP URL, or unix:///path/to/socket)`,
+			EnvVar: `PROCWATCH_API`,
+		},
+		cli.StringFlag{
+			Name:   `username, U`,
+			Usage:  `The username to authenticate with for client operations`,
+			EnvVar: `PROCWATCH_USERNAME`,
+		},
+		cli.StringFlag{
+			Name:   `password, P`,
+			Usage:  `The password to authenticate with for client operations`,
+			EnvVar: `PROCWATCH_PASSWORD`,
+		},
+		cli.StringFlag{
+			Name:   `token, T`,
+			Usage:  `An API token to authenticate with for client operations`,
+			EnvVar: `PROCWATCH_TOKEN`,
+		},
+		cli.StringFlag{
+			Name:   `ca`,
+			Usage:  `A PEM file of CA certificates to verify the server's certificate with for client operations`,
+			EnvVar: `PROCWATCH_CA`,
+		},
+		cli.StringFlag{
+			Name:   `cert`,
+			Usage:  `A PEM client certificate to present for client operations`,
+			EnvVar: `PROCWATCH_CERT`,
+		},
+		cli.StringFlag{
+			Name:   `key`,
+			Usage:  `The PEM private key of the --cert client certificate`,
+			EnvVar: `PROCWATCH_KEY`,
 		},
 		cli.BoolFlag{
 			Name:  `dashboard, D`,
@@ -51,6 +82,12 @@ func main() {
 		},
 	}
 
+	app.Commands = append(ctlCommands(), cli.Command{
+		Name:        `ctl`,
+		Usage:       `Control a running procwatch instance`,
+		Subcommands: ctlCommands(),
+	})
+
 	app.Action = func(c *cli.Context) {
 		var configFile string
 
@@ -67,10 +104,40 @@ func main() {
 
 		var manager = procwatch.NewManagerFromConfig(configFile)
 		signalChan := make(chan os.Signal, 1)
-		signal.Notify(signalChan, os.Interrupt)
+		signal.Notify(
+			signalChan,
+			os.Interrupt,
+			syscall.SIGTERM,
+			syscall.SIGQUIT,
+			syscall.SIGHUP,
+			syscall.SIGUSR1,
+			syscall.SIGUSR2,
+		)
 
 		go func() {
 			for sig := range signalChan {
+				switch sig {
+				case syscall.SIGHUP:
+					manager.ForwardSignal(procwatch.SIGHUP)
+					go reloadConfig(manager)
+					continue
+
+				case syscall.SIGUSR1:
+					manager.ForwardSignal(procwatch.SIGUSR1)
+					continue
+
+				case syscall.SIGUSR2:
+					log.Infof("Received SIGUSR2, reopening log files...")
+
+					if err := manager.ReopenLogs(); err != nil {
+						log.Errorf("Failed to reopen log files: %v", err)
+					}
+
+					manager.ForwardSignal(procwatch.SIGUSR2)
+					continue
+				}
+
+				// SIGINT, SIGTERM, and SIGQUIT all stop the programs gracefully before exiting
 				log.Infof("Received signal %v, stopping all programs...", sig)
 				exitCode := make(chan int)
 
@@ -116,7 +183,7 @@ func main() {
 			go manager.Run()
 
 			if c.Bool(`dashboard`) {
-				go launchDashboard(c.String(`client-address`))
+				go launchDashboard(c)
 			}
 
 			manager.Wait()
@@ -128,10 +195,57 @@ func main() {
 	app.Run(os.Args)
 }
 
-func launchDashboard(clientAddress string) {
-	if dashboard, err := NewDashboard(clientAddress); err == nil {
+// rereads the configuration file and applies any changes to the running programs
+func reloadConfig(manager *procwatch.Manager) {
+	log.Infof("Received SIGHUP, reloading configuration...")
+
+	if diff, err := manager.Update(); err == nil {
+		log.Infof("Configuration reloaded: added=%v changed=%v removed=%v", diff.Added, diff.Changed, diff.Removed)
+	} else {
+		log.Errorf("Failed to reload configuration: %v", err)
+	}
+
+	if manager.Server != nil && manager.Server.TLSEnabled() {
+		if err := manager.Server.ReloadTLS(); err == nil {
+			log.Infof("Reloaded TLS certificates")
+		} else {
+			log.Errorf("Failed to reload TLS certificates: %v", err)
+		}
+	}
+}
+
+// Creates a client using the global --client-address, --username, --password, --token, --ca,
+// --cert, and --key options.
+func newClient(c *cli.Context) (*client.Client, error) {
+	var flag = func(name string) string {
+		if v := c.GlobalString(name); v != `` {
+			return v
+		}
+
+		return c.String(name)
+	}
+
+	if client, err := client.NewClient(
+		flag(`client-address`),
+		client.WithCA(flag(`ca`)),
+		client.WithClientCertificate(flag(`cert`), flag(`key`)),
+	); err == nil {
+		if token := flag(`token`); token != `` {
+			client.SetToken(token)
+		} else if username := flag(`username`); username != `` {
+			client.SetBasicAuth(username, flag(`password`))
+		}
+
+		return client, nil
+	} else {
+		return nil, err
+	}
+}
+
+func launchDashboard(c *cli.Context) {
+	if client, err := newClient(c); err == nil {
 		log.SetOutput(io.Discard)
-		log.FatalIf(dashboard.Run())
+		log.FatalIf(NewDashboard(client).Run())
 	} else {
 		log.FatalIf(err)
 	}
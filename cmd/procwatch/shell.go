@@ -0,0 +1,504 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/ghetzel/go-stockutil/sliceutil"
+	"github.com/ghetzel/go-stockutil/typeutil"
+	"golang.org/x/term"
+)
+
+var ShellPrompt = `procwatch> `
+var ShellHistorySize = 1000
+
+type shellCommand struct {
+	usage string
+	help  string
+	fn    func(shell *Shell, args []string)
+}
+
+var shellCommands map[string]shellCommand
+
+func init() {
+	shellCommands = map[string]shellCommand{
+		`status`: {
+			`status [<name> ..]`,
+			`Show the status of all programs, or of the named ones.`,
+			func(shell *Shell, args []string) { shell.ctl.Status(args) },
+		},
+		`start`: {
+			`start <name|group:*|all> ..`,
+			`Start programs.`,
+			func(shell *Shell, args []string) { shell.ctl.Action(`start`, args) },
+		},
+		`stop`: {
+			`stop <name|group:*|all> ..`,
+			`Stop programs.`,
+			func(shell *Shell, args []string) { shell.ctl.Action(`stop`, args) },
+		},
+		`restart`: {
+			`restart <name|group:*|all> ..`,
+			`Restart programs.`,
+			func(shell *Shell, args []string) { shell.ctl.Action(`restart`, args) },
+		},
+		`signal`: {
+			`signal <signal> <name|group:*|all> ..`,
+			`Send a signal to programs.`,
+			func(shell *Shell, args []string) {
+				if len(args) > 0 {
+					shell.ctl.Signal(args[0], args[1:])
+				} else {
+					shell.ctl.Signal(``, nil)
+				}
+			},
+		},
+		`tail`: {
+			`tail [-f] <name> [stdout|stderr]`,
+			`Show the end of a program's output.  With -f, keep printing output until Ctrl-C is pressed.`,
+			(*Shell).tail,
+		},
+		`reread`: {
+			`reread`,
+			`Reload the configuration file and show which groups would change, without applying anything.`,
+			(*Shell).reread,
+		},
+		`update`: {
+			`update [all|<group> ..]`,
+			`Reload the configuration file and add, remove or restart groups as needed.`,
+			(*Shell).update,
+		},
+		`avail`: {
+			`avail`,
+			`Show the programs that are configured.`,
+			(*Shell).avail,
+		},
+		`help`: {
+			`help [<command>]`,
+			`Show help for all commands, or for the named one.`,
+			(*Shell).help,
+		},
+		`exit`: {
+			`exit`,
+			`Exit the shell.`,
+			nil,
+		},
+	}
+}
+
+// A Shell is an interactive, supervisorctl-style prompt for controlling a procwatch instance.
+type Shell struct {
+	ctl     *Controller
+	history *shellHistory
+}
+
+func NewShell(ctl *Controller) *Shell {
+	var shell = &Shell{
+		ctl: ctl,
+	}
+
+	if home, err := os.UserHomeDir(); err == nil {
+		shell.history = newShellHistory(filepath.Join(home, `.cache`, `procwatch`, `history`))
+	} else {
+		shell.history = newShellHistory(``)
+	}
+
+	return shell
+}
+
+// Reads and executes commands until "exit" or end of input.
+func (shell *Shell) Run() error {
+	var fd = int(os.Stdin.Fd())
+
+	// not interactive: execute commands from standard input
+	if !term.IsTerminal(fd) {
+		var scanner = bufio.NewScanner(os.Stdin)
+
+		for scanner.Scan() {
+			if !shell.Execute(scanner.Text()) {
+				return nil
+			}
+		}
+
+		return scanner.Err()
+	}
+
+	var terminal = term.NewTerminal(struct {
+		io.Reader
+		io.Writer
+	}{os.Stdin, os.Stdout}, ShellPrompt)
+
+	terminal.History = shell.history
+	terminal.AutoCompleteCallback = shell.complete
+
+	for {
+		var line string
+
+		// the terminal is only in raw mode while reading input, so that commands (and Ctrl-C)
+		// behave normally while they run
+		if state, err := term.MakeRaw(fd); err == nil {
+			if width, height, err := term.GetSize(fd); err == nil {
+				terminal.SetSize(width, height)
+			}
+
+			line, err = terminal.ReadLine()
+			term.Restore(fd, state)
+
+			if err == io.EOF {
+				fmt.Println()
+				return nil
+			} else if err != nil {
+				return err
+			}
+		} else {
+			return err
+		}
+
+		if !shell.Execute(line) {
+			return nil
+		}
+	}
+}
+
+// Executes a single command line, returning false if the shell should exit.
+func (shell *Shell) Execute(line string) bool {
+	var args = strings.Fields(line)
+
+	if len(args) == 0 {
+		return true
+	}
+
+	switch args[0] {
+	case `exit`, `quit`:
+		return false
+	}
+
+	if command, ok := shellCommands[args[0]]; ok && command.fn != nil {
+		command.fn(shell, args[1:])
+	} else {
+		fmt.Printf("*** Unknown syntax: %s\n", line)
+	}
+
+	return true
+}
+
+func (shell *Shell) help(args []string) {
+	if len(args) > 0 {
+		if command, ok := shellCommands[args[0]]; ok {
+			fmt.Printf("%s\n    %s\n", command.usage, command.help)
+		} else {
+			fmt.Printf("*** No help on %s\n", args[0])
+		}
+
+		return
+	}
+
+	for _, name := range shellCommandNames() {
+		fmt.Printf("  %-40s %s\n", shellCommands[name].usage, shellCommands[name].help)
+	}
+}
+
+func (shell *Shell) tail(args []string) {
+	var follow bool
+	var positional = make([]string, 0)
+
+	for _, arg := range args {
+		if arg == `-f` {
+			follow = true
+		} else {
+			positional = append(positional, arg)
+		}
+	}
+
+	var ctx, cancel = context.WithCancel(context.Background())
+	var interrupts = make(chan os.Signal, 1)
+	defer cancel()
+
+	// Ctrl-C stops following instead of exiting the shell
+	signal.Notify(interrupts, os.Interrupt)
+	defer signal.Stop(interrupts)
+
+	go func() {
+		select {
+		case <-interrupts:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	shell.ctl.Tail(ctx, typeutil.String(sliceutil.Get(positional, 0)), typeutil.String(sliceutil.Get(positional, 1)), follow)
+
+	if follow {
+		fmt.Println()
+	}
+}
+
+// returns the names of the groups that were added, changed, and removed in the config file
+func (shell *Shell) rereadConfig() ([]string, []string, []string, error) {
+	if result, err := shell.ctl.client.CallRPC(`supervisor.reloadConfig`); err == nil {
+		var diff = sliceutil.Get(sliceutil.Get(result, 0), 0)
+
+		return sliceutil.Stringify(sliceutil.Get(diff, 0)),
+			sliceutil.Stringify(sliceutil.Get(diff, 1)),
+			sliceutil.Stringify(sliceutil.Get(diff, 2)),
+			nil
+	} else {
+		return nil, nil, nil, err
+	}
+}
+
+func (shell *Shell) reread(args []string) {
+	shell.ctl.Reload(true)
+}
+
+func (shell *Shell) update(args []string) {
+	if len(args) == 0 || sliceutil.ContainsString(args, `all`) {
+		shell.ctl.Reload(false)
+		return
+	}
+
+	var added, changed, removed, err = shell.rereadConfig()
+
+	if err != nil {
+		fmt.Printf("ERROR: %v\n", err)
+		return
+	}
+
+	var selected = func(name string) bool {
+		return sliceutil.ContainsString(args, name)
+	}
+
+	var stopAndRemove = func(name string) bool {
+		if _, err := shell.ctl.client.CallRPC(`supervisor.stopProcessGroup`, name); err != nil {
+			fmt.Printf("%s: ERROR (%v)\n", name, err)
+			return false
+		}
+
+		fmt.Printf("%s: stopped\n", name)
+
+		if _, err := shell.ctl.client.CallRPC(`supervisor.removeProcessGroup`, name); err != nil {
+			fmt.Printf("%s: ERROR (%v)\n", name, err)
+			return false
+		}
+
+		return true
+	}
+
+	for _, name := range removed {
+		if selected(name) && stopAndRemove(name) {
+			fmt.Printf("%s: removed process group\n", name)
+		}
+	}
+
+	for _, name := range changed {
+		if selected(name) && stopAndRemove(name) {
+			if _, err := shell.ctl.client.CallRPC(`supervisor.addProcessGroup`, name); err == nil {
+				fmt.Printf("%s: updated process group\n", name)
+			} else {
+				fmt.Printf("%s: ERROR (%v)\n", name, err)
+			}
+		}
+	}
+
+	for _, name := range added {
+		if selected(name) {
+			if _, err := shell.ctl.client.CallRPC(`supervisor.addProcessGroup`, name); err == nil {
+				fmt.Printf("%s: added process group\n", name)
+			} else {
+				fmt.Printf("%s: ERROR (%v)\n", name, err)
+			}
+		}
+	}
+}
+
+func (shell *Shell) avail(args []string) {
+	if programs, err := shell.ctl.client.GetPrograms(); err == nil {
+		for _, program := range programs {
+			var auto = `manual`
+
+			if program.AutoStart {
+				auto = `auto`
+			}
+
+			fmt.Printf("%-32s in use    %-6s %d:%d\n", program.FullName(), auto, program.Priority, program.Priority)
+		}
+	} else {
+		fmt.Printf("ERROR: %v\n", err)
+	}
+
+	if added, _, _, err := shell.rereadConfig(); err == nil {
+		for _, name := range added {
+			fmt.Printf("%-32s avail\n", name+`:*`)
+		}
+	}
+}
+
+// the words that can complete the given (partial) command line
+func (shell *Shell) completions(words []string) []string {
+	if len(words) <= 1 {
+		return shellCommandNames()
+	}
+
+	switch words[0] {
+	case `help`:
+		return shellCommandNames()
+	case `status`, `start`, `stop`, `restart`, `signal`, `tail`, `update`:
+		var names = []string{`all`}
+
+		if words[0] == `tail` {
+			names = []string{`stdout`, `stderr`}
+		}
+
+		if programs, err := shell.ctl.client.GetPrograms(); err == nil {
+			for _, program := range programs {
+				names = append(names, program.FullName())
+
+				if program.FullName() != program.Name {
+					names = append(names, program.Group+`:*`)
+				}
+			}
+		}
+
+		return sliceutil.UniqueStrings(names)
+	}
+
+	return nil
+}
+
+// called by the terminal on every keypress; completes the word under the cursor when Tab is pressed
+func (shell *Shell) complete(line string, pos int, key rune) (string, int, bool) {
+	if key != '\t' {
+		return ``, 0, false
+	}
+
+	var head = line[:pos]
+	var words = strings.Fields(head)
+
+	// the cursor is at the start of a new word
+	if len(words) == 0 || strings.HasSuffix(head, ` `) {
+		words = append(words, ``)
+	}
+
+	var partial = words[len(words)-1]
+	var matches = make([]string, 0)
+
+	for _, candidate := range shell.completions(words) {
+		if strings.HasPrefix(candidate, partial) {
+			matches = append(matches, candidate)
+		}
+	}
+
+	if len(matches) == 0 {
+		return ``, 0, false
+	}
+
+	var completed = commonPrefix(matches)
+
+	if len(matches) == 1 {
+		completed += ` `
+	}
+
+	var prefix = head[:len(head)-len(partial)]
+
+	return prefix + completed + line[pos:], len(prefix) + len(completed), true
+}
+
+func commonPrefix(words []string) string {
+	var prefix = words[0]
+
+	for _, word := range words[1:] {
+		for !strings.HasPrefix(word, prefix) {
+			prefix = prefix[:len(prefix)-1]
+		}
+	}
+
+	return prefix
+}
+
+func shellCommandNames() []string {
+	var names = make([]string, 0, len(shellCommands))
+
+	for name := range shellCommands {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+	return names
+}
+
+// shellHistory is a term.History that persists entries to a file.
+type shellHistory struct {
+	filename string
+	entries  []string
+}
+
+func newShellHistory(filename string) *shellHistory {
+	var history = &shellHistory{
+		filename: filename,
+		entries:  make([]string, 0),
+	}
+
+	if filename != `` {
+		if data, err := os.ReadFile(filename); err == nil {
+			for _, line := range strings.Split(string(data), "\n") {
+				if line != `` {
+					history.entries = append(history.entries, line)
+				}
+			}
+		}
+
+		// the file is appended to as commands are entered, so it's trimmed back here
+		if len(history.entries) > ShellHistorySize {
+			history.entries = history.entries[len(history.entries)-ShellHistorySize:]
+			history.save()
+		}
+	}
+
+	return history
+}
+
+func (history *shellHistory) Add(entry string) {
+	if strings.TrimSpace(entry) == `` {
+		return
+	}
+
+	history.entries = append(history.entries, entry)
+
+	if len(history.entries) > ShellHistorySize {
+		history.entries = history.entries[1:]
+	}
+
+	if history.filename != `` {
+		if err := os.MkdirAll(filepath.Dir(history.filename), 0700); err == nil {
+			if file, err := os.OpenFile(history.filename, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600); err == nil {
+				fmt.Fprintln(file, entry)
+				file.Close()
+			}
+		}
+	}
+}
+
+// Replaces the history file with the entries currently held.
+func (history *shellHistory) save() {
+	var data = strings.Join(history.entries, "\n") + "\n"
+
+	if err := os.MkdirAll(filepath.Dir(history.filename), 0700); err == nil {
+		os.WriteFile(history.filename, []byte(data), 0600)
+	}
+}
+
+func (history *shellHistory) Len() int {
+	return len(history.entries)
+}
+
+// index 0 is the most recent entry
+func (history *shellHistory) At(idx int) string {
+	return history.entries[len(history.entries)-1-idx]
+}
@@ -0,0 +1,155 @@
+package procwatch
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/user"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+var ErrCannotSwitchUser = errors.New(`cannot run programs as another user unless procwatch is running as root`)
+
+// Looks up the user named in the program's "user" option, which may be a username or a
+// numeric UID.  Returns nil if no user is configured.
+func (program *Program) runAsUser() (*user.User, error) {
+	if program.User == `` {
+		return nil, nil
+	}
+
+	if u, err := user.Lookup(program.User); err == nil {
+		return u, nil
+	} else if _, convErr := strconv.Atoi(program.User); convErr == nil {
+		return user.LookupId(program.User)
+	} else {
+		return nil, err
+	}
+}
+
+// Returns the credentials the program's process should be started with, or nil if it should
+// run as the same user as procwatch.
+func (program *Program) credential() (*syscall.Credential, error) {
+	var u, err = program.runAsUser()
+
+	if err != nil {
+		return nil, fmt.Errorf("user %q: %v", program.User, err)
+	} else if u == nil {
+		return nil, nil
+	}
+
+	var credential = new(syscall.Credential)
+
+	if uid, err := strconv.ParseUint(u.Uid, 10, 32); err == nil {
+		credential.Uid = uint32(uid)
+	} else {
+		return nil, fmt.Errorf("user %q: invalid uid %q", program.User, u.Uid)
+	}
+
+	if gid, err := strconv.ParseUint(u.Gid, 10, 32); err == nil {
+		credential.Gid = uint32(gid)
+	} else {
+		return nil, fmt.Errorf("user %q: invalid gid %q", program.User, u.Gid)
+	}
+
+	// already running as this user; nothing to drop
+	if int(credential.Uid) == os.Geteuid() {
+		return nil, nil
+	} else if os.Geteuid() != 0 {
+		return nil, fmt.Errorf("user %q: %w", program.User, ErrCannotSwitchUser)
+	}
+
+	if groups, err := u.GroupIds(); err == nil {
+		for _, group := range groups {
+			if gid, err := strconv.ParseUint(group, 10, 32); err == nil {
+				credential.Groups = append(credential.Groups, uint32(gid))
+			}
+		}
+	} else {
+		return nil, fmt.Errorf("user %q: failed to get groups: %v", program.User, err)
+	}
+
+	return credential, nil
+}
+
+// returns a function that makes the command run with the given credentials
+func withCredential(credential *syscall.Credential) func(*exec.Cmd) {
+	return func(c *exec.Cmd) {
+		// SysProcAttr has already been populated (e.g.: to put the process in its own
+		// process group), so only the credentials are set here.
+		if c.SysProcAttr == nil {
+			c.SysProcAttr = new(syscall.SysProcAttr)
+		}
+
+		c.SysProcAttr.Credential = credential
+	}
+}
+
+// Returns the HOME, USER, and LOGNAME variables for the user the program runs as.
+func (program *Program) userEnvironment() []string {
+	if u, err := program.runAsUser(); err == nil && u != nil {
+		return []string{
+			`HOME=` + u.HomeDir,
+			`USER=` + u.Username,
+			`LOGNAME=` + u.Username,
+		}
+	}
+
+	return nil
+}
+
+// Creates the given log file (if it doesn't exist) and makes it owned by the user the
+// program runs as.
+func (program *Program) chownLogfile(logfile string) error {
+	if credential, err := program.credential(); err == nil && credential != nil {
+		if file, err := os.OpenFile(logfile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644); err == nil {
+			file.Close()
+		} else {
+			return err
+		}
+
+		return os.Chown(logfile, int(credential.Uid), int(credential.Gid))
+	} else {
+		return err
+	}
+}
+
+// Resolves an owner given as "user" or "user:group" (by name or numeric ID) into a UID and
+// GID.  If no group is given, the user's primary group is used.
+func lookupOwner(owner string) (int, int, error) {
+	var username, groupname, _ = strings.Cut(owner, `:`)
+	var u *user.User
+	var err error
+
+	if u, err = user.Lookup(username); err != nil {
+		if _, convErr := strconv.Atoi(username); convErr == nil {
+			u, err = user.LookupId(username)
+		}
+	}
+
+	if err != nil {
+		return 0, 0, err
+	}
+
+	var gid = u.Gid
+
+	if groupname != `` {
+		if g, err := user.LookupGroup(groupname); err == nil {
+			gid = g.Gid
+		} else if _, convErr := strconv.Atoi(groupname); convErr == nil {
+			gid = groupname
+		} else {
+			return 0, 0, err
+		}
+	}
+
+	if uid, err := strconv.Atoi(u.Uid); err != nil {
+		return 0, 0, err
+	} else if gid, err := strconv.Atoi(gid); err != nil {
+		return 0, 0, err
+	} else {
+		return uid, gid, nil
+	}
+}
@@ -0,0 +1,194 @@
+package procwatch
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+	"unicode"
+)
+
+// Parses a Supervisor-style "environment" value (e.g.: KEY1="value1",KEY2='value 2',KEY3=x)
+// into KEY=VALUE pairs.  Values may be double-quoted, in which a backslash escapes the next
+// character, or single-quoted, in which everything is literal; outside of quotes, a backslash
+// also escapes the next character and whitespace around keys and values is ignored.
+func ParseEnvironment(value string) ([]string, error) {
+	var pairs = make([]string, 0)
+	var runes = []rune(value)
+	var key, val strings.Builder
+	var field = &key
+	var inValue bool
+	var quote rune
+	var pending string
+	var started bool
+
+	var write = func(r rune) {
+		if field.Len() > 0 {
+			field.WriteString(pending)
+		}
+
+		pending = ``
+		field.WriteRune(r)
+		started = true
+	}
+
+	var finish = func() error {
+		pending = ``
+
+		if !started {
+			return nil
+		} else if !inValue {
+			return fmt.Errorf("expected KEY=VALUE, got %q", key.String())
+		} else if key.Len() == 0 {
+			return fmt.Errorf("missing variable name before %q", `=`+val.String())
+		}
+
+		pairs = append(pairs, key.String()+`=`+val.String())
+		key.Reset()
+		val.Reset()
+		field = &key
+		inValue = false
+		started = false
+
+		return nil
+	}
+
+	for i := 0; i < len(runes); i++ {
+		var r = runes[i]
+
+		switch {
+		case quote != 0 && r == quote:
+			quote = 0
+		case quote == '"' && r == '\\' && i+1 < len(runes):
+			i++
+			write(runes[i])
+		case quote != 0:
+			write(r)
+		case r == '\\' && i+1 < len(runes):
+			i++
+			write(runes[i])
+		case r == '"' || r == '\'':
+			// an empty quoted value still counts as a value
+			if field.Len() > 0 {
+				field.WriteString(pending)
+			}
+
+			pending = ``
+			quote = r
+			started = true
+		case r == '=' && !inValue:
+			field = &val
+			inValue = true
+			pending = ``
+			started = true
+		case r == ',':
+			if err := finish(); err != nil {
+				return nil, err
+			}
+		case unicode.IsSpace(r):
+			pending += string(r)
+		default:
+			write(r)
+		}
+	}
+
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated %c quote", quote)
+	} else if err := finish(); err != nil {
+		return nil, err
+	}
+
+	return pairs, nil
+}
+
+// Reads KEY=VALUE pairs from a dotenv-style file.  Blank lines and lines starting with "#" are
+// skipped, and an "export " prefix is ignored.  Double-quoted values may contain \n, \t, \" and
+// \\ escapes, single-quoted values are literal, and unquoted values end at a " #" comment.
+func LoadEnvFile(filename string) ([]string, error) {
+	var file, err = os.Open(filename)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer file.Close()
+
+	var pairs = make([]string, 0)
+	var scanner = bufio.NewScanner(file)
+	var lineno int
+
+	for scanner.Scan() {
+		var line = strings.TrimSpace(scanner.Text())
+
+		lineno += 1
+
+		if line == `` || strings.HasPrefix(line, `#`) {
+			continue
+		}
+
+		line = strings.TrimPrefix(line, `export `)
+
+		var key, value, ok = strings.Cut(line, `=`)
+
+		if key = strings.TrimSpace(key); !ok || key == `` {
+			return nil, fmt.Errorf("%s:%d: expected KEY=VALUE", filename, lineno)
+		}
+
+		if value, err = parseEnvFileValue(strings.TrimSpace(value)); err == nil {
+			pairs = append(pairs, key+`=`+value)
+		} else {
+			return nil, fmt.Errorf("%s:%d: %v", filename, lineno, err)
+		}
+	}
+
+	return pairs, scanner.Err()
+}
+
+func parseEnvFileValue(value string) (string, error) {
+	if value == `` {
+		return ``, nil
+	}
+
+	switch quote := value[0]; quote {
+	case '\'':
+		if end := strings.IndexByte(value[1:], '\''); end >= 0 {
+			return value[1 : end+1], nil
+		}
+
+		return ``, fmt.Errorf("unterminated ' quote")
+
+	case '"':
+		var out strings.Builder
+
+		for i := 1; i < len(value); i++ {
+			switch c := value[i]; {
+			case c == '"':
+				return out.String(), nil
+			case c == '\\' && i+1 < len(value):
+				i++
+
+				switch value[i] {
+				case 'n':
+					out.WriteByte('\n')
+				case 't':
+					out.WriteByte('\t')
+				case 'r':
+					out.WriteByte('\r')
+				default:
+					out.WriteByte(value[i])
+				}
+			default:
+				out.WriteByte(c)
+			}
+		}
+
+		return ``, fmt.Errorf("unterminated \" quote")
+
+	default:
+		if comment := strings.Index(value, ` #`); comment >= 0 {
+			value = value[:comment]
+		}
+
+		return strings.TrimSpace(value), nil
+	}
+}
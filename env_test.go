@@ -0,0 +1,140 @@
+package procwatch
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseEnvironment(t *testing.T) {
+	assert := require.New(t)
+
+	env, err := ParseEnvironment(`A="x,y",B="z"`)
+	assert.NoError(err)
+	assert.Equal([]string{`A=x,y`, `B=z`}, env)
+
+	env, err = ParseEnvironment(` KEY = some value , QUOTED="a \"b\" \\c", SINGLE='\n', ESCAPED=1\,2,EMPTY="",BARE=`)
+	assert.NoError(err)
+	assert.Equal([]string{
+		`KEY=some value`,
+		`QUOTED=a "b" \c`,
+		`SINGLE=\n`,
+		`ESCAPED=1,2`,
+		`EMPTY=`,
+		`BARE=`,
+	}, env)
+
+	env, err = ParseEnvironment(`URL=http://x/?a=b`)
+	assert.NoError(err)
+	assert.Equal([]string{`URL=http://x/?a=b`}, env)
+
+	env, err = ParseEnvironment(``)
+	assert.NoError(err)
+	assert.Empty(env)
+
+	_, err = ParseEnvironment(`A="x`)
+	assert.Error(err)
+
+	_, err = ParseEnvironment(`A=1,B`)
+	assert.Error(err)
+
+	_, err = ParseEnvironment(`=1`)
+	assert.Error(err)
+}
+
+func TestLoadEnvFile(t *testing.T) {
+	assert := require.New(t)
+
+	env, err := LoadEnvFile(`./tests/environment.env`)
+	assert.NoError(err)
+	assert.Equal([]string{
+		`FROM_FILE=yes`,
+		"QUOTED=line one\nline two",
+		`LITERAL=$HOME \n`,
+		`OVERRIDDEN=file`,
+	}, env)
+
+	var bad = filepath.Join(t.TempDir(), `bad.env`)
+	assert.NoError(os.WriteFile(bad, []byte("OK=1\nNOPE\n"), 0600))
+
+	_, err = LoadEnvFile(bad)
+	assert.ErrorContains(err, `bad.env:2`)
+}
+
+func TestProgramEnvironment(t *testing.T) {
+	assert := require.New(t)
+	t.Setenv(`PROCWATCH_TEST_INHERITED`, `yes`)
+
+	manager, err := newManager(`environment`)
+	assert.NoError(err)
+	assert.Equal([]string{`SHARED=manager`, `OVERRIDDEN=manager`}, manager.Environment)
+
+	program, ok := manager.Program(`inherit`)
+	assert.True(ok)
+	assert.Equal([]string{`A=x,y`, `B=a, b`, `C=spaced out`, `OVERRIDDEN=program`}, program.Environment)
+
+	env, err := program.getEnvironment()
+	assert.NoError(err)
+	assert.Contains(env, `PROCWATCH_TEST_INHERITED=yes`)
+	assert.Contains(env, `SHARED=manager`)
+	assert.Contains(env, `FROM_FILE=yes`)
+	assert.Contains(env, `A=x,y`)
+
+	// the last value of a variable is the one the process sees
+	assert.Equal(`OVERRIDDEN=program`, env[len(env)-1])
+
+	program, ok = manager.Program(`clean`)
+	assert.True(ok)
+
+	env, err = program.getEnvironment()
+	assert.NoError(err)
+	assert.NotContains(env, `PROCWATCH_TEST_INHERITED=yes`)
+	assert.Equal(append(program.procwatchEnvironment(), `SHARED=manager`, `OVERRIDDEN=manager`, `ONLY=this`), env)
+
+	program.EnvFiles = []string{`./tests/nonexistent.env`}
+	_, err = program.getEnvironment()
+	assert.Error(err)
+}
+
+func TestProgramProcwatchEnvironment(t *testing.T) {
+	assert := require.New(t)
+
+	manager, err := newManager(`environment`)
+	assert.NoError(err)
+
+	// wait for the API server to be listening so that its real port is known
+	time.Sleep(250 * time.Millisecond)
+
+	var url = manager.Server.URL()
+	assert.Regexp(`^http://localhost:[1-9][0-9]*$`, url)
+
+	program, ok := manager.Program(`inherit`)
+	assert.True(ok)
+
+	env, err := program.getEnvironment()
+	assert.NoError(err)
+
+	for _, pair := range []string{
+		`SUPERVISOR_ENABLED=1`,
+		`SUPERVISOR_PROCESS_NAME=inherit`,
+		`SUPERVISOR_GROUP_NAME=inherit`,
+		`SUPERVISOR_SERVER_URL=` + url,
+		`PROCWATCH_PROGRAM=inherit`,
+		`PROCWATCH_INSTANCE=0`,
+		`PROCWATCH_API=` + url,
+	} {
+		assert.Contains(env, pair)
+	}
+
+	// an explicit serverurl is passed along as-is
+	program, ok = manager.Program(`clean`)
+	assert.True(ok)
+
+	env, err = program.getEnvironment()
+	assert.NoError(err)
+	assert.Contains(env, `SUPERVISOR_SERVER_URL=unix:///tmp/elsewhere.sock`)
+	assert.Contains(env, `PROCWATCH_API=unix:///tmp/elsewhere.sock`)
+}
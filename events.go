@@ -3,6 +3,7 @@ package procwatch
 import (
 	"fmt"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/ghetzel/go-stockutil/sliceutil"
@@ -23,18 +24,23 @@ func (src EventSource) String() string {
 	}
 }
 
+var eventSerial int64
+
 type Event struct {
+	Serial     int64
 	Names      []string
 	Label      string
 	Timestamp  time.Time
 	Error      error
 	Arguments  []string
+	Data       string
 	SourceType EventSource
 	Source     any
 }
 
 func NewEvent(names []string, label string, sourceType EventSource, source any, args ...string) *Event {
 	return &Event{
+		Serial:     atomic.AddInt64(&eventSerial, 1),
 		Names:      names,
 		Label:      label,
 		Timestamp:  time.Now(),
@@ -53,3 +59,22 @@ func (event *Event) String() string {
 func (event *Event) HasName(name string) bool {
 	return sliceutil.ContainsString(event.Names, name)
 }
+
+// Returns the most specific of the event's names (e.g.: PROCESS_STATE_RUNNING rather than PROCESS_STATE).
+func (event *Event) Name() string {
+	if len(event.Names) > 0 {
+		return event.Names[len(event.Names)-1]
+	}
+
+	return ``
+}
+
+// Returns the event body as sent to event listeners: the event's arguments as
+// space-separated "key:value" tokens, followed by a newline and the event's data (if any).
+func (event *Event) Payload() string {
+	if event.Data != `` {
+		return strings.Join(event.Arguments, ` `) + "\n" + event.Data
+	}
+
+	return strings.Join(event.Arguments, ` `)
+}
@@ -0,0 +1,110 @@
+package procwatch
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ghetzel/go-stockutil/sliceutil"
+	"github.com/ghetzel/go-stockutil/stringutil"
+	"github.com/go-ini/ini"
+)
+
+// A Group is a named collection of programs that can be controlled together.  Groups are
+// declared with [group:name] sections; every program that isn't a member of one is placed
+// in a group of its own, named after the program.
+type Group struct {
+	Name     string   `json:"name"               ini:"-"`
+	Programs []string `json:"programs"           delim:"," ini:"programs"`
+	Priority int      `json:"priority,omitempty" ini:"priority,omitempty"`
+	Implicit bool     `json:"implicit,omitempty" ini:"-"`
+	manager  *Manager
+}
+
+func NewGroup(name string, manager *Manager) *Group {
+	return &Group{
+		Name:     name,
+		Programs: make([]string, 0),
+		Priority: 999,
+		manager:  manager,
+	}
+}
+
+func (group *Group) String() string {
+	return group.Name
+}
+
+// Returns whether the named program (as given in its [program:x] section) is in this group.
+func (group *Group) Contains(programName string) bool {
+	return sliceutil.ContainsString(group.Programs, programName)
+}
+
+// Returns every program instance that belongs to this group, in load order.
+func (group *Group) Members() []*Program {
+	var members = make([]*Program, 0)
+
+	for _, program := range group.manager.Programs() {
+		if program.Group == group.Name {
+			members = append(members, program)
+		}
+	}
+
+	return members
+}
+
+// Returns the priority of the program's group.  Programs in an implicit group take their own
+// priority, as there are no other members to order them among.
+func (program *Program) groupPriority() int {
+	if program.manager != nil {
+		if group, ok := program.manager.Group(program.Group); ok && !group.Implicit {
+			return group.Priority
+		}
+	}
+
+	return program.Priority
+}
+
+func (group *Group) Start() {
+	for _, program := range group.Members() {
+		program.Start()
+	}
+}
+
+func (group *Group) Stop() {
+	for _, program := range group.Members() {
+		program.Stop()
+	}
+}
+
+func (group *Group) Restart() {
+	group.Stop()
+	group.Start()
+}
+
+func LoadGroupsFromConfig(data []byte, manager *Manager) ([]*Group, error) {
+	var groups = make([]*Group, 0)
+
+	if iniFile, err := ini.Load(data); err == nil {
+		for _, section := range iniFile.Sections() {
+			if strings.HasPrefix(section.Name(), `group:`) {
+				var _, name = stringutil.SplitPair(section.Name(), `:`)
+				var group = NewGroup(name, manager)
+
+				if err := section.MapTo(group); err == nil {
+					group.Programs = sliceutil.CompactString(sliceutil.TrimSpace(group.Programs))
+
+					if len(group.Programs) == 0 {
+						return nil, fmt.Errorf("group:%v: must specify at least one program", name)
+					}
+
+					groups = append(groups, group)
+				} else {
+					return nil, fmt.Errorf("group:%v: %v", name, err)
+				}
+			}
+		}
+	} else {
+		return nil, err
+	}
+
+	return groups, nil
+}
@@ -0,0 +1,123 @@
+package procwatch
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+
+	"github.com/ghetzel/go-stockutil/stringutil"
+	"github.com/ghetzel/go-stockutil/typeutil"
+)
+
+var rxInterpolation = regexp.MustCompile(`%%|%\(([^\)]+)\)([#0\- +]*[0-9]*(?:\.[0-9]+)?)([sdiouxXeEfFgGr])`)
+
+// Interpolate expands Supervisor-style %(name)s expressions in the given string using
+// the values in vars.  Flags, width, and precision are honored the same way Python's
+// string formatting operator would, so "%(process_num)02d" yields "01".  A literal percent
+// sign may be written as "%%".
+func Interpolate(in string, vars map[string]any) (string, error) {
+	var ierr error
+
+	var out = rxInterpolation.ReplaceAllStringFunc(in, func(match string) string {
+		if match == `%%` {
+			return `%`
+		}
+
+		var parts = rxInterpolation.FindStringSubmatch(match)
+		var key, spec, verb = parts[1], parts[2], parts[3]
+
+		if value, ok := vars[key]; ok {
+			switch verb {
+			case `d`, `i`, `u`:
+				return fmt.Sprintf("%"+spec+"d", typeutil.Int(value))
+			case `o`, `x`, `X`:
+				return fmt.Sprintf("%"+spec+verb, typeutil.Int(value))
+			case `e`, `E`, `f`, `F`, `g`, `G`:
+				return fmt.Sprintf("%"+spec+verb, typeutil.Float(value))
+			default:
+				return fmt.Sprintf("%"+spec+"s", typeutil.String(value))
+			}
+		} else if ierr == nil {
+			ierr = fmt.Errorf("unknown interpolation key %q in %q", key, in)
+		}
+
+		return match
+	})
+
+	return out, ierr
+}
+
+// Returns the variables available for interpolation in any configuration value: %(here)s,
+// %(host_node_name)s, and %(ENV_*)s for every variable in the manager's environment.  Any
+// additional values given are merged in.
+func (manager *Manager) interpolationVars(extra map[string]any) map[string]any {
+	var vars = make(map[string]any)
+
+	if here := manager.here; here != `` {
+		vars[`here`] = here
+	} else if cwd, err := os.Getwd(); err == nil {
+		vars[`here`] = cwd
+	}
+
+	if hostname, err := os.Hostname(); err == nil {
+		vars[`host_node_name`] = hostname
+	}
+
+	for _, pair := range os.Environ() {
+		var key, value = stringutil.SplitPair(pair, `=`)
+		vars[`ENV_`+key] = value
+	}
+
+	for key, value := range extra {
+		vars[key] = value
+	}
+
+	return vars
+}
+
+// Expands all interpolation expressions in the program's configuration values.
+func (program *Program) interpolate() error {
+	var vars = program.manager.interpolationVars(map[string]any{
+		`program_name`: program.ProgramName,
+		`process_num`:  program.ProcessNum,
+		`group_name`:   program.Group,
+	})
+
+	for label, value := range map[string]*string{
+		`directory`:      &program.Directory,
+		`stdout_logfile`: &program.StdoutLogfile,
+		`stderr_logfile`: &program.StderrLogfile,
+	} {
+		if expanded, err := Interpolate(*value, vars); err == nil {
+			*value = expanded
+		} else {
+			return fmt.Errorf("%s: %v", label, err)
+		}
+	}
+
+	if command, ok := program.Command.(string); ok {
+		if expanded, err := Interpolate(command, vars); err == nil {
+			program.Command = expanded
+		} else {
+			return fmt.Errorf("command: %v", err)
+		}
+	}
+
+	for i, pair := range program.Environment {
+		if expanded, err := Interpolate(pair, vars); err == nil {
+			program.Environment[i] = expanded
+		} else {
+			return fmt.Errorf("environment: %v", err)
+		}
+	}
+
+	for i, filename := range program.EnvFiles {
+		if expanded, err := Interpolate(filename, vars); err == nil {
+			program.EnvFiles[i] = expanded
+		} else {
+			return fmt.Errorf("env_file: %v", err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,30 @@
+package procwatch
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestInterpolate(t *testing.T) {
+	assert := require.New(t)
+	vars := map[string]any{
+		`program_name`: `worker`,
+		`process_num`:  7,
+	}
+
+	out, err := Interpolate(`%(program_name)s_%(process_num)02d`, vars)
+	assert.NoError(err)
+	assert.Equal(`worker_07`, out)
+
+	out, err = Interpolate(`%(process_num)d%% of %(program_name)5s`, vars)
+	assert.NoError(err)
+	assert.Equal(`7% of worker`, out)
+
+	out, err = Interpolate(`date +%s`, vars)
+	assert.NoError(err)
+	assert.Equal(`date +%s`, out)
+
+	_, err = Interpolate(`%(nope)s`, vars)
+	assert.Error(err)
+}
@@ -0,0 +1,383 @@
+package procwatch
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ghetzel/go-stockutil/log"
+	"github.com/ghetzel/go-stockutil/sliceutil"
+)
+
+const EventListenerProtocolVersion = `3.0`
+const DefaultEventListenerBufferSize = 10
+
+// How long a listener is given to read an event before it is considered stuck.
+var EventListenerWriteTimeout = 5 * time.Second
+
+type ListenerState string
+
+const (
+	ListenerAcknowledged ListenerState = `ACKNOWLEDGED`
+	ListenerReady        ListenerState = `READY`
+	ListenerBusy         ListenerState = `BUSY`
+	ListenerUnknown      ListenerState = `UNKNOWN`
+)
+
+// An EventListenerPool holds the events destined for the processes of a single
+// [eventlistener:x] section.  Each event is delivered to exactly one READY member of the
+// pool; events that arrive while every member is busy are buffered until one is ready.
+type EventListenerPool struct {
+	Name        string   `json:"name"`
+	Events      []string `json:"events"`
+	BufferSize  int      `json:"buffer_size"`
+	manager     *Manager
+	buffer      []*Event
+	serial      int64
+	members     []*eventListener
+	dispatching bool
+	lock        sync.Mutex
+}
+
+func newEventListenerPool(name string, events []string, bufferSize int, manager *Manager) *EventListenerPool {
+	if bufferSize <= 0 {
+		bufferSize = DefaultEventListenerBufferSize
+	}
+
+	return &EventListenerPool{
+		Name:       name,
+		Events:     events,
+		BufferSize: bufferSize,
+		manager:    manager,
+		buffer:     make([]*Event, 0),
+		members:    make([]*eventListener, 0),
+	}
+}
+
+// Returns whether this pool has subscribed to the given event.
+func (pool *EventListenerPool) Accepts(event *Event) bool {
+	for _, name := range pool.Events {
+		if name == `EVENT` || event.HasName(name) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Returns the number of events waiting to be delivered.
+func (pool *EventListenerPool) Buffered() int {
+	pool.lock.Lock()
+	defer pool.lock.Unlock()
+
+	return len(pool.buffer)
+}
+
+// Adds an event to the pool's buffer (if the pool is subscribed to it) and attempts to deliver it.
+func (pool *EventListenerPool) Push(event *Event) {
+	if !pool.Accepts(event) {
+		return
+	}
+
+	pool.lock.Lock()
+
+	if len(pool.buffer) >= pool.BufferSize {
+		log.Warningf("[%s] event buffer overflowed, discarding event %d", pool.Name, pool.buffer[0].Serial)
+		pool.buffer = pool.buffer[1:]
+	}
+
+	pool.buffer = append(pool.buffer, event)
+	pool.lock.Unlock()
+
+	pool.wake()
+}
+
+// Puts an event that was not successfully handled back at the head of the buffer.
+func (pool *EventListenerPool) requeue(event *Event) {
+	pool.lock.Lock()
+	pool.buffer = append([]*Event{event}, pool.buffer...)
+	pool.lock.Unlock()
+}
+
+// Starts delivering buffered events in the background, unless the pool already is.  Each pool
+// delivers on its own goroutine, so a listener that isn't reading its events holds up neither
+// the manager's event loop nor other pools.
+func (pool *EventListenerPool) wake() {
+	pool.lock.Lock()
+	defer pool.lock.Unlock()
+
+	if !pool.dispatching {
+		pool.dispatching = true
+		go pool.dispatch()
+	}
+}
+
+// Delivers buffered events to READY listeners until either runs out.
+func (pool *EventListenerPool) dispatch() {
+	for {
+		pool.lock.Lock()
+
+		if len(pool.buffer) == 0 {
+			pool.dispatching = false
+			pool.lock.Unlock()
+			return
+		}
+
+		var target *eventListener
+
+		for _, member := range pool.members {
+			if member.claim() {
+				target = member
+				break
+			}
+		}
+
+		if target == nil {
+			pool.dispatching = false
+			pool.lock.Unlock()
+			return
+		}
+
+		var event = pool.buffer[0]
+		pool.buffer = pool.buffer[1:]
+		pool.serial += 1
+		var poolSerial = pool.serial
+		pool.lock.Unlock()
+
+		// the listener is left UNKNOWN, so the event goes to another one (if any are ready)
+		if err := target.send(event, poolSerial); err != nil {
+			log.Warningf("[%s] failed to send event %d: %v", target.program.Name, event.Serial, err)
+			pool.requeue(event)
+		}
+	}
+}
+
+// eventListener tracks the protocol state of a single event listener process.
+type eventListener struct {
+	program *Program
+	pool    *EventListenerPool
+	state   ListenerState
+	stdin   *os.File
+	current *Event
+	pending []byte
+	expect  int
+	lock    sync.Mutex
+}
+
+// Marks the listener busy if it is ready to accept an event.
+func (listener *eventListener) claim() bool {
+	listener.lock.Lock()
+	defer listener.lock.Unlock()
+
+	if listener.state == ListenerReady && listener.stdin != nil {
+		listener.state = ListenerBusy
+		return true
+	}
+
+	return false
+}
+
+// Writes the event header and payload to the listener's standard input.
+func (listener *eventListener) send(event *Event, poolSerial int64) error {
+	listener.lock.Lock()
+	defer listener.lock.Unlock()
+
+	var payload = event.Payload()
+	var header = fmt.Sprintf(
+		"ver:%s server:procwatch serial:%d pool:%s poolserial:%d eventname:%s len:%d\n",
+		EventListenerProtocolVersion,
+		event.Serial,
+		listener.pool.Name,
+		poolSerial,
+		event.Name(),
+		len(payload),
+	)
+
+	listener.current = event
+
+	if err := listener.stdin.SetWriteDeadline(time.Now().Add(EventListenerWriteTimeout)); err != nil {
+		listener.current = nil
+		listener.state = ListenerUnknown
+		return err
+	} else if _, err := listener.stdin.WriteString(header + payload); err != nil {
+		listener.current = nil
+		listener.state = ListenerUnknown
+		return err
+	}
+
+	return nil
+}
+
+// Called when the listener process has been started; stdin is the write end of its
+// standard input.
+func (listener *eventListener) attach(stdin *os.File) {
+	listener.lock.Lock()
+	defer listener.lock.Unlock()
+
+	listener.stdin = stdin
+	listener.state = ListenerAcknowledged
+	listener.current = nil
+	listener.pending = nil
+	listener.expect = 0
+}
+
+// Called once the listener process has exited.  Any event it was handling is returned to the pool.
+func (listener *eventListener) detach() {
+	listener.lock.Lock()
+	var inflight = listener.current
+
+	if listener.stdin != nil {
+		listener.stdin.Close()
+		listener.stdin = nil
+	}
+
+	listener.current = nil
+	listener.state = ListenerAcknowledged
+	listener.lock.Unlock()
+
+	if inflight != nil {
+		listener.pool.requeue(inflight)
+	}
+}
+
+// Processes a line of output from the listener process.  Outside of the READY and RESULT
+// tokens of the protocol, output is logged like that of any other program.
+func (listener *eventListener) handleOutput(line string) {
+	listener.lock.Lock()
+
+	// we're in the middle of reading a result body; the newline was consumed by the line
+	// splitting so it is added back to the data.
+	if listener.expect > 0 {
+		var data = append(listener.pending, []byte(line+"\n")...)
+
+		if len(data) < listener.expect {
+			listener.pending = data
+			listener.lock.Unlock()
+			return
+		}
+
+		var result = string(data[:listener.expect])
+		var rest = strings.TrimSuffix(string(data[listener.expect:]), "\n")
+
+		listener.pending = nil
+		listener.expect = 0
+		listener.lock.Unlock()
+
+		listener.handleResult(result)
+
+		if rest != `` {
+			listener.handleOutput(rest)
+		}
+
+		return
+	}
+
+	var token = strings.TrimSpace(line)
+
+	switch {
+	case token == `READY`:
+		listener.state = ListenerReady
+		listener.lock.Unlock()
+		listener.pool.wake()
+		return
+
+	case strings.HasPrefix(token, `RESULT `):
+		if n, err := strconv.Atoi(strings.TrimPrefix(token, `RESULT `)); err == nil && n > 0 && listener.state == ListenerBusy {
+			listener.expect = n
+			listener.lock.Unlock()
+			return
+		}
+
+		listener.lock.Unlock()
+		listener.protocolError(fmt.Errorf("unexpected %q", token))
+		return
+	}
+
+	listener.lock.Unlock()
+	listener.program.Log(line, true)
+}
+
+func (listener *eventListener) handleResult(result string) {
+	listener.lock.Lock()
+	var event = listener.current
+	listener.current = nil
+	listener.state = ListenerAcknowledged
+	listener.lock.Unlock()
+
+	if event == nil {
+		return
+	}
+
+	switch result {
+	case `OK`:
+		log.Debugf("[%s] event %d processed", listener.program.Name, event.Serial)
+	case `FAIL`:
+		log.Warningf("[%s] event %d rejected, requeueing", listener.program.Name, event.Serial)
+		listener.pool.requeue(event)
+	default:
+		log.Warningf("[%s] event %d got unknown result %q, requeueing", listener.program.Name, event.Serial, result)
+		listener.pool.requeue(event)
+	}
+}
+
+func (listener *eventListener) protocolError(err error) {
+	listener.lock.Lock()
+	var inflight = listener.current
+	listener.current = nil
+	listener.state = ListenerUnknown
+	listener.lock.Unlock()
+
+	log.Warningf("[%s] event listener protocol error: %v", listener.program.Name, err)
+
+	if inflight != nil {
+		listener.pool.requeue(inflight)
+	}
+}
+
+// Returns the pool for the named [eventlistener:x] section, creating it if necessary, and
+// adds the program to it.
+func (manager *Manager) addEventListener(program *Program) error {
+	if len(program.Events) == 0 {
+		return fmt.Errorf("eventlistener:%v: must specify events", program.ProgramName)
+	}
+
+	var pool *EventListenerPool
+
+	for _, p := range manager.EventListenerPools() {
+		if p.Name == program.ProgramName {
+			pool = p
+			break
+		}
+	}
+
+	if pool == nil {
+		pool = newEventListenerPool(program.ProgramName, sliceutil.CompactString(sliceutil.TrimSpace(program.Events)), program.BufferSize, manager)
+
+		manager.reloadLock.Lock()
+		manager.listenerPools = append(manager.listenerPools, pool)
+		manager.reloadLock.Unlock()
+	}
+
+	program.listener = &eventListener{
+		program: program,
+		pool:    pool,
+		state:   ListenerAcknowledged,
+	}
+
+	pool.lock.Lock()
+	pool.members = append(pool.members, program.listener)
+	pool.lock.Unlock()
+
+	return nil
+}
+
+// Returns a copy of the manager's event listener pools.
+func (manager *Manager) EventListenerPools() []*EventListenerPool {
+	manager.reloadLock.RLock()
+	defer manager.reloadLock.RUnlock()
+
+	return append([]*EventListenerPool{}, manager.listenerPools...)
+}
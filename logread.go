@@ -0,0 +1,147 @@
+package procwatch
+
+import (
+	"errors"
+	"io"
+	"os"
+	"strings"
+)
+
+// The number of bytes read from the end of a log when no offset is given.
+const DefaultLogTailBytes = 1600
+
+var ErrNoLogFile = errors.New(`program does not log to a file`)
+var ErrInvalidLogRange = errors.New(`invalid log offset or length`)
+
+// A LogChunk is a portion of a log file read with ReadLog.
+type LogChunk struct {
+	Data   string `json:"data"`
+	Offset int64  `json:"offset"`
+	Size   int64  `json:"size"`
+}
+
+// Returns the path of the file the given stream is logged to, or ErrNoLogFile if the stream
+// isn't being written to a file.
+func (program *Program) LogfileName(stdout bool) (string, error) {
+	var logfile = program.LogfilePath(stdout)
+
+	switch strings.ToLower(logfile) {
+	case `none`, `stdout`, `stderr`, ``:
+		return ``, ErrNoLogFile
+	default:
+		return logfile, nil
+	}
+}
+
+// Reads from the program's stdout or stderr log.  See ReadLogFile for how offset and length
+// are interpreted.
+func (program *Program) ReadLog(stdout bool, offset int64, length int64) (*LogChunk, error) {
+	if logfile, err := program.LogfileName(stdout); err == nil {
+		return ReadLogFile(logfile, offset, length)
+	} else {
+		return nil, err
+	}
+}
+
+// Reads length bytes from the given file starting at offset, the way Supervisor's readLog
+// methods do.  A negative offset reads that many bytes from the end of the file, and a
+// length of zero reads to the end.  The returned chunk's Offset is where the next read
+// should start to pick up where this one left off.
+func ReadLogFile(filename string, offset int64, length int64) (*LogChunk, error) {
+	if length < 0 || (offset < 0 && length != 0) {
+		return nil, ErrInvalidLogRange
+	}
+
+	var file, err = os.Open(filename)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer file.Close()
+
+	var chunk = new(LogChunk)
+
+	if stat, err := file.Stat(); err == nil {
+		chunk.Size = stat.Size()
+	} else {
+		return nil, err
+	}
+
+	if offset < 0 {
+		offset = chunk.Size + offset
+	}
+
+	if offset < 0 {
+		offset = 0
+	} else if offset > chunk.Size {
+		offset = chunk.Size
+	}
+
+	var reader io.Reader = io.NewSectionReader(file, offset, chunk.Size-offset)
+
+	if length > 0 {
+		reader = io.LimitReader(reader, length)
+	}
+
+	if data, err := io.ReadAll(reader); err == nil {
+		chunk.Data = string(data)
+		chunk.Offset = offset + int64(len(data))
+
+		return chunk, nil
+	} else {
+		return nil, err
+	}
+}
+
+// Reads the last length bytes of the given file the way Supervisor's tailProcessLog methods
+// do, returning the data, the offset to use for the next call, and whether more than length
+// bytes were written since the given offset.
+func TailLogFile(filename string, offset int64, length int64) (string, int64, bool, error) {
+	if offset < 0 || length < 0 {
+		return ``, 0, false, ErrInvalidLogRange
+	}
+
+	var file, err = os.Open(filename)
+
+	if err != nil {
+		return ``, 0, false, nil
+	}
+
+	defer file.Close()
+
+	var size int64
+
+	if stat, err := file.Stat(); err == nil {
+		size = stat.Size()
+	} else {
+		return ``, 0, false, err
+	}
+
+	var overflow = false
+
+	if size > (offset + length) {
+		overflow = true
+		offset = size - 1
+	}
+
+	if (offset + length) > size {
+		if offset > (size - 1) {
+			length = 0
+		}
+
+		offset = size - length
+	}
+
+	if offset < 0 {
+		offset = 0
+	}
+
+	var data = make([]byte, length)
+
+	if n, err := file.ReadAt(data, offset); err == nil || err == io.EOF {
+		return string(data[:n]), size, overflow, nil
+	} else {
+		return ``, 0, false, err
+	}
+}
@@ -0,0 +1,51 @@
+package procwatch
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadLogFile(t *testing.T) {
+	assert := require.New(t)
+	var filename = filepath.Join(t.TempDir(), `test.log`)
+
+	assert.NoError(os.WriteFile(filename, []byte("0123456789"), 0644))
+
+	chunk, err := ReadLogFile(filename, 0, 0)
+	assert.NoError(err)
+	assert.Equal(`0123456789`, chunk.Data)
+	assert.EqualValues(10, chunk.Offset)
+	assert.EqualValues(10, chunk.Size)
+
+	chunk, err = ReadLogFile(filename, -4, 0)
+	assert.NoError(err)
+	assert.Equal(`6789`, chunk.Data)
+
+	chunk, err = ReadLogFile(filename, -40, 0)
+	assert.NoError(err)
+	assert.Equal(`0123456789`, chunk.Data)
+
+	chunk, err = ReadLogFile(filename, 2, 3)
+	assert.NoError(err)
+	assert.Equal(`234`, chunk.Data)
+	assert.EqualValues(5, chunk.Offset)
+
+	chunk, err = ReadLogFile(filename, 20, 0)
+	assert.NoError(err)
+	assert.Equal(``, chunk.Data)
+
+	_, err = ReadLogFile(filename, -4, 2)
+	assert.ErrorIs(err, ErrInvalidLogRange)
+
+	_, err = ReadLogFile(filename+`.missing`, 0, 0)
+	assert.ErrorIs(err, os.ErrNotExist)
+
+	data, offset, overflow, err := TailLogFile(filename, 0, 4)
+	assert.NoError(err)
+	assert.Equal(`6789`, data)
+	assert.EqualValues(10, offset)
+	assert.True(overflow)
+}
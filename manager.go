@@ -6,6 +6,7 @@ import (
 	"io"
 	"os/user"
 	"path/filepath"
+	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -57,12 +58,21 @@ type Manager struct {
 	StdoutLogfileBackups  int         `json:"stdout_logfile_backups"  ini:"stdout_logfile_backups"`
 	DefaultStdoutLogfile  string      `json:"stdout_logfile"          ini:"stdout_logfile"`
 	DefaultStderrLogfile  string      `json:"stderr_logfile"          ini:"stderr_logfile"`
+	Environment           []string    `json:"environment,omitempty"   ini:"-"`
+	EnvironmentString     string      `json:"-"                       ini:"environment"`
 	Server                *Server     `json:"server"                  ini:"server"`
 	Events                chan *Event `json:"-"`
 	includes              []string
+	here                  string
 	loadedConfigs         []string
 	eventHandlers         []EventHandler
 	programs              []*Program
+	groups                []*Group
+	listenerPools         []*EventListenerPool
+	reread                *Manager
+	reloadLock            *sync.RWMutex
+	updateLock            *sync.Mutex
+	startLock             *sync.Mutex
 	stopping              bool
 	doneStopping          chan error
 	externalWaiters       chan bool
@@ -72,7 +82,16 @@ type Manager struct {
 }
 
 func NewManager() *Manager {
-	var manager = &Manager{
+	var manager = newManagerWithDefaults()
+
+	// register the log intercept function for this manager
+	manager.intercept = log.AddLogIntercept(manager.Log)
+
+	return manager
+}
+
+func newManagerWithDefaults() *Manager {
+	return &Manager{
 		Version:               Version,
 		LogFileMaxBytes:       `50MB`,
 		StdoutLogfileMaxBytes: `50MB`,
@@ -84,17 +103,17 @@ func NewManager() *Manager {
 			Address: DefaultAddress,
 		},
 		programs:        make([]*Program, 0),
+		groups:          make([]*Group, 0),
+		listenerPools:   make([]*EventListenerPool, 0),
 		eventHandlers:   make([]EventHandler, 0),
 		doneStopping:    make(chan error),
 		includes:        make([]string, 0),
 		loadedConfigs:   make([]string, 0),
 		externalWaiters: make(chan bool),
+		reloadLock:      new(sync.RWMutex),
+		updateLock:      new(sync.Mutex),
+		startLock:       new(sync.Mutex),
 	}
-
-	// register the log intercept function for this manager
-	manager.intercept = log.AddLogIntercept(manager.Log)
-
-	return manager
 }
 
 func NewManagerFromConfig(configFile string) *Manager {
@@ -115,9 +134,53 @@ func (manager *Manager) Initialize() error {
 		manager.Events = make(chan *Event)
 	}
 
+	if err := manager.loadConfig(); err != nil {
+		return err
+	}
+
+	if manager.ChildLogDir == `` {
+		if u, err := user.Current(); err == nil && u.Uid == `0` {
+			manager.ChildLogDir = `/var/log/procwatch`
+		} else {
+			manager.ChildLogDir, _ = fileutil.ExpandUser(`~/.cache/procwatch`)
+		}
+	}
+
+	if manager.LogFile == `` {
+		manager.LogFile = filepath.Join(manager.ChildLogDir, `procwatch.log`)
+	}
+
+	if manager.LogFileMaxBytes != `` {
+		if b, err := humanize.ParseBytes(manager.LogFileMaxBytes); err == nil {
+			manager.logFileMaxBytes = b
+		} else {
+			return fmt.Errorf("logfile_maxbytes: %v", err)
+		}
+	} else {
+		manager.LogFileMaxBytes = DefaultLogFileMaxBytes.To(convutil.Megabyte)
+		manager.logFileMaxBytes = uint64(DefaultLogFileMaxBytes)
+	}
+
+	if manager.Server != nil {
+		if err := manager.Server.Initialize(manager); err == nil {
+			go manager.Server.Start()
+		} else {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Loads the main configuration file and all of the configurations it includes.
+func (manager *Manager) loadConfig() error {
+	var files = make([]*configFile, 0)
+
 	// load main config
 	if manager.ConfigFile != `` {
-		if err := manager.loadConfigFromFile(manager.ConfigFile); err != nil {
+		if file, err := manager.loadConfigFromFile(manager.ConfigFile); err == nil {
+			files = append(files, file)
+		} else {
 			return err
 		}
 
@@ -130,7 +193,8 @@ func (manager *Manager) Initialize() error {
 							return fmt.Errorf("already loaded configuration at %s", includedConfig)
 						}
 
-						if err := manager.loadConfigFromFile(includedConfig); err == nil {
+						if file, err := manager.loadConfigFromFile(includedConfig); err == nil {
+							files = append(files, file)
 							manager.loadedConfigs = append(manager.loadedConfigs, includedConfig)
 						} else {
 							return err
@@ -145,34 +209,22 @@ func (manager *Manager) Initialize() error {
 		}
 	}
 
-	if manager.ChildLogDir == `` {
-		if u, err := user.Current(); err == nil && u.Uid == `0` {
-			manager.ChildLogDir = `/var/log/procwatch`
-		} else {
-			manager.ChildLogDir, _ = fileutil.ExpandUser(`~/.cache/procwatch`)
-		}
-	}
-
-	if manager.LogFile == `` {
-		manager.LogFile = filepath.Join(manager.ChildLogDir, `procwatch.log`)
-	}
+	// programs are only added once every group is known, since a group in an included file
+	// may claim programs from the main one (or vice versa)
+	for _, file := range files {
+		manager.here = file.here
 
-	if manager.LogFileMaxBytes != `` {
-		if b, err := humanize.ParseBytes(manager.LogFileMaxBytes); err == nil {
-			manager.logFileMaxBytes = b
-		} else {
-			return fmt.Errorf("logfile_maxbytes: %v", err)
+		if _, err := LoadProgramsFromConfig(file.data, manager); err != nil {
+			return err
 		}
-	} else {
-		manager.LogFileMaxBytes = DefaultLogFileMaxBytes.To(convutil.Megabyte)
-		manager.logFileMaxBytes = uint64(DefaultLogFileMaxBytes)
 	}
 
-	if manager.Server != nil {
-		if err := manager.Server.Initialize(manager); err == nil {
-			go manager.Server.Start()
-		} else {
-			return err
+	// every program named by a [group:x] section must exist once all configs are loaded
+	for _, group := range manager.Groups() {
+		for _, programName := range group.Programs {
+			if len(manager.ProgramInstances(programName)) == 0 {
+				return fmt.Errorf("group:%v: unknown program %q", group.Name, programName)
+			}
 		}
 	}
 
@@ -183,41 +235,127 @@ func (manager *Manager) AddProgram(program *Program) error {
 	newprogram := NewProgram(program.Name, manager)
 
 	if err := structutil.CopyNonZero(newprogram, program); err == nil {
+		if group, err := manager.groupFor(newprogram.ProgramName); err == nil {
+			newprogram.Group = group.Name
+		} else {
+			return err
+		}
+
+		if err := newprogram.interpolate(); err != nil {
+			return err
+		}
+
+		if newprogram.EventListener {
+			if err := manager.addEventListener(newprogram); err != nil {
+				return err
+			}
+		}
+
+		manager.reloadLock.Lock()
 		newprogram.LoadIndex = len(manager.programs)
 		manager.programs = append(manager.programs, newprogram)
+		manager.reloadLock.Unlock()
+
 		return nil
 	} else {
 		return err
 	}
 }
 
-func (manager *Manager) loadConfigFromFile(filename string) error {
+// Returns the group the named program belongs to, creating an implicit group for it if it
+// isn't named in any [group:x] section.
+func (manager *Manager) groupFor(programName string) (*Group, error) {
+	for _, group := range manager.Groups() {
+		if group.Contains(programName) {
+			return group, nil
+		}
+	}
+
+	if group, ok := manager.Group(programName); ok {
+		if group.Implicit {
+			return group, nil
+		} else {
+			return nil, fmt.Errorf("program %q conflicts with the name of group %q", programName, group.Name)
+		}
+	}
+
+	var group = NewGroup(programName, manager)
+	group.Implicit = true
+	group.Programs = []string{programName}
+
+	manager.reloadLock.Lock()
+	manager.groups = append(manager.groups, group)
+	manager.reloadLock.Unlock()
+
+	return group, nil
+}
+
+func (manager *Manager) AddGroup(group *Group) error {
+	if _, ok := manager.Group(group.Name); ok {
+		return fmt.Errorf("group:%v: already defined", group.Name)
+	}
+
+	for _, other := range manager.Groups() {
+		for _, programName := range group.Programs {
+			if other.Contains(programName) {
+				return fmt.Errorf("group:%v: program %q is already in group %q", group.Name, programName, other.Name)
+			}
+		}
+	}
+
+	group.manager = manager
+
+	manager.reloadLock.Lock()
+	manager.groups = append(manager.groups, group)
+	manager.reloadLock.Unlock()
+
+	return nil
+}
+
+// A configuration file whose global settings and groups have been loaded, and whose programs
+// are yet to be.
+type configFile struct {
+	here string
+	data []byte
+}
+
+func (manager *Manager) loadConfigFromFile(filename string) (*configFile, error) {
 	filename = fileutil.MustExpandUser(filename)
 	log.Infof("Loading configuration file: %s", filename)
 
+	// %(here)s expands to the directory containing the file currently being loaded
+	if abs, err := filepath.Abs(filename); err == nil {
+		manager.here = filepath.Dir(abs)
+	}
+
 	if stream, err := fileutil.Retrieve(context.Background(), filename); err == nil {
 		defer stream.Close()
 
 		if data, err := io.ReadAll(stream); err == nil {
 			if err := LoadGlobalConfig(data, manager); err != nil {
-				return err
+				return nil, err
 			}
 
-			if loaded, err := LoadProgramsFromConfig(data, manager); err == nil {
-				for _, program := range loaded {
-					manager.AddProgram(program)
+			if groups, err := LoadGroupsFromConfig(data, manager); err == nil {
+				for _, group := range groups {
+					if err := manager.AddGroup(group); err != nil {
+						return nil, err
+					}
 				}
 			} else {
-				return err
+				return nil, err
 			}
+
+			return &configFile{
+				here: manager.here,
+				data: data,
+			}, nil
 		} else {
-			return err
+			return nil, err
 		}
 	} else {
-		return err
+		return nil, err
 	}
-
-	return nil
 }
 
 func (manager *Manager) Run() {
@@ -225,10 +363,12 @@ func (manager *Manager) Run() {
 
 	go manager.startEventLogger()
 
+	manager.startInPriorityOrder(manager.Programs())
+
 	for {
 		var checkLock sync.WaitGroup
 
-		for _, program := range manager.programs {
+		for _, program := range manager.Programs() {
 			checkLock.Add(1)
 			go manager.checkProgramState(program, &checkLock)
 		}
@@ -254,19 +394,112 @@ func (manager *Manager) Wait() {
 func (manager *Manager) Stop(force bool) {
 	manager.stopping = true
 
-	for _, program := range manager.programs {
-		if force {
-			log.Warningf("Force stopping program %s", program.Name)
-			program.ForceStop()
-		} else {
-			log.Infof("Stopping program %s", program.Name)
-			program.Stop()
+	// let a tier that is still being started finish, so none of its programs are started after
+	// they've been stopped
+	manager.startLock.Lock()
+	manager.startLock.Unlock()
+
+	// stop programs in descending priority order, waiting for each tier to stop before moving
+	// on to the next one
+	for _, tier := range priorityTiers(manager.Programs(), true) {
+		var tierLock sync.WaitGroup
+
+		for _, program := range tier {
+			tierLock.Add(1)
+
+			go func(program *Program) {
+				defer tierLock.Done()
+
+				if force {
+					log.Warningf("Force stopping program %s", program.Name)
+					program.ForceStop()
+				} else {
+					log.Infof("Stopping program %s", program.Name)
+					program.Stop()
+				}
+			}(program)
 		}
+
+		tierLock.Wait()
 	}
 
 	log.Infof("All programs stopped, stopping manager...")
 }
 
+// Starts the given autostart programs in ascending priority order.  Programs of the same priority
+// are started together, and the next tier isn't started until every program in the current one
+// has either reached RUNNING or failed to start.
+func (manager *Manager) startInPriorityOrder(programs []*Program) {
+	manager.startLock.Lock()
+	defer manager.startLock.Unlock()
+
+	var autostart = make([]*Program, 0)
+
+	for _, program := range programs {
+		if program.AutoStart && !program.HasEverBeenStarted() {
+			autostart = append(autostart, program)
+		}
+	}
+
+	for _, tier := range priorityTiers(autostart, false) {
+		var tierLock sync.WaitGroup
+
+		if manager.stopping {
+			return
+		}
+
+		log.Debugf("Starting %d program(s) with priority %d", len(tier), tier[0].Priority)
+
+		for _, program := range tier {
+			tierLock.Add(1)
+
+			go func(program *Program) {
+				defer tierLock.Done()
+
+				log.Debugf("[%s] Starting program for the first time", program.Name)
+				program.ShouldAutoRestart() // do this here to "seed" the scheduler with the first schedule time
+				program.Start()
+			}(program)
+		}
+
+		tierLock.Wait()
+	}
+}
+
+// Groups programs by priority, ordered from lowest to highest (or highest to lowest if reverse
+// is true).  Programs with the same priority keep their configured order.
+func priorityTiers(programs []*Program, reverse bool) [][]*Program {
+	var sorted = make([]*Program, len(programs))
+	var tiers = make([][]*Program, 0)
+
+	copy(sorted, programs)
+
+	// programs are ordered by the priority of their group, then by their own
+	sort.SliceStable(sorted, func(i int, j int) bool {
+		var a, b = sorted[i], sorted[j]
+
+		if reverse {
+			a, b = b, a
+		}
+
+		if ga, gb := a.groupPriority(), b.groupPriority(); ga != gb {
+			return ga < gb
+		} else {
+			return a.Priority < b.Priority
+		}
+	})
+
+	for i, program := range sorted {
+		if i == 0 || program.Priority != sorted[i-1].Priority || program.groupPriority() != sorted[i-1].groupPriority() {
+			tiers = append(tiers, make([]*Program, 0))
+		}
+
+		tiers[len(tiers)-1] = append(tiers[len(tiers)-1], program)
+	}
+
+	return tiers
+}
+
 func (manager *Manager) AddEventHandler(handler EventHandler) {
 	manager.eventHandlers = append(manager.eventHandlers, handler)
 }
@@ -296,15 +529,9 @@ func (manager *Manager) checkProgramState(program *Program, checkLock *sync.Wait
 		return
 	}
 
+	// first-time starts are left to startInPriorityOrder, so that programs added while running
+	// are started in priority order too
 	switch program.GetState() {
-	case ProgramStopped:
-		// first-time start for autostart programs
-		if program.AutoStart && !program.HasEverBeenStarted() {
-			log.Debugf("[%s] Starting program for the first time", program.Name)
-			program.ShouldAutoRestart() // do this here to "seed" the scheduler with the first schedule time
-			program.Start()
-		}
-
 	case ProgramExited:
 		// automatic restart of cleanly-exited programs
 		if program.ShouldAutoRestart() {
@@ -329,13 +556,17 @@ func (manager *Manager) checkProgramState(program *Program, checkLock *sync.Wait
 	}
 }
 
+// Returns a copy of the manager's programs, in load order.
 func (manager *Manager) Programs() []*Program {
-	return manager.programs
+	manager.reloadLock.RLock()
+	defer manager.reloadLock.RUnlock()
+
+	return append([]*Program{}, manager.programs...)
 }
 
 func (manager *Manager) Program(name string) (*Program, bool) {
-	for _, program := range manager.programs {
-		if program.Name == name {
+	for _, program := range manager.Programs() {
+		if program.Name == name || program.FullName() == name {
 			return program, true
 		}
 	}
@@ -343,10 +574,60 @@ func (manager *Manager) Program(name string) (*Program, bool) {
 	return nil, false
 }
 
+// Returns a copy of the manager's groups.
+func (manager *Manager) Groups() []*Group {
+	manager.reloadLock.RLock()
+	defer manager.reloadLock.RUnlock()
+
+	return append([]*Group{}, manager.groups...)
+}
+
+func (manager *Manager) Group(name string) (*Group, bool) {
+	for _, group := range manager.Groups() {
+		if group.Name == name {
+			return group, true
+		}
+	}
+
+	return nil, false
+}
+
+// Resolves a name as given to a control command into the programs it refers to.  Names may
+// refer to a single process by name or as "group:name", to every member of a group as
+// "group:*" or "group", or to all instances of a program with numprocs > 1.
+func (manager *Manager) Resolve(name string) ([]*Program, error) {
+	if program, ok := manager.Program(name); ok {
+		return []*Program{program}, nil
+	}
+
+	var groupName = strings.TrimSuffix(name, `:*`)
+
+	if group, ok := manager.Group(groupName); ok {
+		return group.Members(), nil
+	} else if instances := manager.ProgramInstances(name); len(instances) > 0 {
+		return instances, nil
+	}
+
+	return nil, fmt.Errorf("no such process %q", name)
+}
+
+// Returns all instances of the named program, in the order they were loaded.
+func (manager *Manager) ProgramInstances(programName string) []*Program {
+	var instances = make([]*Program, 0)
+
+	for _, program := range manager.Programs() {
+		if program.ProgramName == programName {
+			instances = append(instances, program)
+		}
+	}
+
+	return instances
+}
+
 func (manager *Manager) GetProgramsByState(states ...ProgramState) []*Program {
 	programs := make([]*Program, 0)
 
-	for _, program := range manager.programs {
+	for _, program := range manager.Programs() {
 		currentState := program.GetState()
 
 		for _, state := range states {
@@ -359,7 +640,35 @@ func (manager *Manager) GetProgramsByState(states ...ProgramState) []*Program {
 	return programs
 }
 
-func (manager *Manager) pushProcessStateEvent(state ProgramState, source *Program, err error, args ...string) {
+func (manager *Manager) pushProcessStateEvent(from ProgramState, state ProgramState, source *Program, err error) {
+	var args = []string{
+		`processname:` + source.Name,
+		`groupname:` + source.Group,
+		`from_state:` + string(from),
+	}
+
+	switch state {
+	case ProgramStarting, ProgramBackoff:
+		args = append(args, fmt.Sprintf("tries:%d", source.processRetryCount))
+	case ProgramRunning:
+		args = append(args, fmt.Sprintf("pid:%d", source.ProcessID))
+	case ProgramStopping, ProgramStopped:
+		args = append(args, fmt.Sprintf("pid:%d", source.ProcessID))
+
+		// report which signal was (or is about to be) sent to stop the program, and to what
+		if source.lastStopSignal != `` {
+			args = append(args, fmt.Sprintf("signal:%s", source.lastStopSignal), fmt.Sprintf("group:%v", source.lastStopAsGroup))
+		}
+	case ProgramExited:
+		var expected = 0
+
+		if source.IsExpectedStatus(source.LastExitStatus) {
+			expected = 1
+		}
+
+		args = append(args, fmt.Sprintf("expected:%d", expected), fmt.Sprintf("pid:%d", source.ProcessID))
+	}
+
 	event := NewEvent([]string{
 		`PROCESS_STATE`,
 		fmt.Sprintf("PROCESS_STATE_%v", state),
@@ -369,6 +678,63 @@ func (manager *Manager) pushProcessStateEvent(state ProgramState, source *Progra
 	manager.Events <- event
 }
 
+// Emits a PROCESS_SIGNAL event for a signal sent to a program other than to stop it.
+func (manager *Manager) pushProcessSignalEvent(source *Program, pid int, signal ProgramSignal, asGroup bool) {
+	manager.Events <- NewEvent([]string{
+		`PROCESS_SIGNAL`,
+	}, source.Name, ProgramSource, source,
+		`processname:`+source.Name,
+		`groupname:`+source.Group,
+		fmt.Sprintf("pid:%d", pid),
+		fmt.Sprintf("signal:%s", signal),
+		fmt.Sprintf("group:%v", asGroup),
+	)
+}
+
+// Emits a PROCESS_LOG_STDOUT (or PROCESS_LOG_STDERR) event carrying output a program wrote.
+func (manager *Manager) pushProcessLogEvent(source *Program, pid int, stdout bool, data string) {
+	var channel = `stderr`
+
+	if stdout {
+		channel = `stdout`
+	}
+
+	var event = NewEvent([]string{
+		`PROCESS_LOG`,
+		`PROCESS_LOG_` + strings.ToUpper(channel),
+	}, source.Name, ProgramSource, source,
+		`processname:`+source.Name,
+		`groupname:`+source.Group,
+		fmt.Sprintf("pid:%d", pid),
+		`channel:`+channel,
+	)
+
+	event.Data = data
+	manager.Events <- event
+}
+
+// Emits a PROCESS_COMMUNICATION_STDOUT (or PROCESS_COMMUNICATION_STDERR) event carrying output
+// a program wrote in capture mode.
+func (manager *Manager) pushProcessCommunicationEvent(source *Program, pid int, stdout bool, data string) {
+	var channel = `STDERR`
+
+	if stdout {
+		channel = `STDOUT`
+	}
+
+	var event = NewEvent([]string{
+		`PROCESS_COMMUNICATION`,
+		`PROCESS_COMMUNICATION_` + channel,
+	}, source.Name, ProgramSource, source,
+		`processname:`+source.Name,
+		`groupname:`+source.Group,
+		fmt.Sprintf("pid:%d", pid),
+	)
+
+	event.Data = data
+	manager.Events <- event
+}
+
 func (manager *Manager) startEventLogger() {
 	for event := range manager.Events {
 		if event.Error != nil {
@@ -381,29 +747,125 @@ func (manager *Manager) startEventLogger() {
 		for _, handler := range manager.eventHandlers {
 			handler(event)
 		}
+
+		// ...and to any event listener pools that have subscribed to it
+		for _, pool := range manager.EventListenerPools() {
+			pool.Push(event)
+		}
 	}
 }
 
 func LoadGlobalConfig(data []byte, manager *Manager) error {
 	if iniFile, err := ini.Load(data); err == nil {
 		for _, section := range iniFile.Sections() {
+			if name, ok := strings.CutPrefix(section.Name(), `token:`); ok {
+				var token = &APIToken{
+					Name: name,
+				}
+
+				if err := section.MapTo(token); err != nil {
+					return err
+				} else if token.Token == `` {
+					return fmt.Errorf("token %s: no token specified", name)
+				}
+
+				manager.Server.Tokens = append(manager.Server.Tokens, token)
+				continue
+			} else if name, ok := strings.CutPrefix(section.Name(), `role:`); ok {
+				var role = &Role{
+					Name: name,
+				}
+
+				if err := section.MapTo(role); err != nil {
+					return err
+				}
+
+				manager.Server.Roles = append(manager.Server.Roles, role)
+				continue
+			}
+
 			switch section.Name() {
 			case `procwatch`, `supervisord`:
 				if err := section.MapTo(manager); err != nil {
 					return err
 				}
+
+				var vars = manager.interpolationVars(nil)
+
+				for label, value := range map[string]*string{
+					`logfile`:     &manager.LogFile,
+					`childlogdir`: &manager.ChildLogDir,
+				} {
+					if expanded, err := Interpolate(*value, vars); err == nil {
+						*value = expanded
+					} else {
+						return fmt.Errorf("%s: %v", label, err)
+					}
+				}
+
+				// these are inherited by every program, beneath the program's own environment
+				if env, err := ParseEnvironment(manager.EnvironmentString); err == nil {
+					for i, pair := range env {
+						if expanded, err := Interpolate(pair, vars); err == nil {
+							env[i] = expanded
+						} else {
+							return fmt.Errorf("environment: %v", err)
+						}
+					}
+
+					manager.Environment = env
+				} else {
+					return fmt.Errorf("environment: %v", err)
+				}
 			case `server`:
 				if key := section.Key(`enabled`); key != nil && key.MustBool(false) {
 					if err := section.MapTo(manager.Server); err != nil {
 						return err
 					}
+
+					if expanded, err := Interpolate(manager.Server.AuditLog, manager.interpolationVars(nil)); err == nil {
+						manager.Server.AuditLog = expanded
+					} else {
+						return fmt.Errorf("server: audit_log: %v", err)
+					}
+				}
+
+			case `unix_http_server`:
+				// Supervisor-style socket configuration; this takes the place of the TCP address
+				var vars = manager.interpolationVars(nil)
+
+				for key, value := range map[string]*string{
+					`file`:     &manager.Server.Address,
+					`chmod`:    &manager.Server.Chmod,
+					`chown`:    &manager.Server.Chown,
+					`username`: &manager.Server.Username,
+					`password`: &manager.Server.Password,
+				} {
+					if v := section.Key(key).String(); v == `` {
+						continue
+					} else if expanded, err := Interpolate(v, vars); err == nil {
+						*value = expanded
+					} else {
+						return fmt.Errorf("unix_http_server: %s: %v", key, err)
+					}
+				}
+
+				if !strings.HasPrefix(manager.Server.Address, `unix://`) && section.HasKey(`file`) {
+					manager.Server.Address = `unix://` + manager.Server.Address
 				}
 
 			case `include`:
 				if key := section.Key(`files`); key != nil {
 					if value := key.MustString(``); value != `` {
-						fileGlobs := strings.Split(value, `,`)
-						manager.includes = append(manager.includes, fileGlobs...)
+						var vars = manager.interpolationVars(nil)
+
+						for _, fileGlob := range strings.Split(value, `,`) {
+							if expanded, err := Interpolate(strings.TrimSpace(fileGlob), vars); err == nil {
+								manager.includes = append(manager.includes, expanded)
+							} else {
+								return fmt.Errorf("include: %v", err)
+							}
+						}
 					}
 				}
 			}
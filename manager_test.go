@@ -0,0 +1,268 @@
+package procwatch
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPriorityOrder(t *testing.T) {
+	assert := require.New(t)
+
+	manager, err := newManager(`priority`)
+	assert.NoError(err)
+
+	var transitions []string
+	var transitionLock sync.Mutex
+
+	manager.AddEventHandler(func(event *Event) {
+		if event.HasName(`PROCESS_STATE`) {
+			transitionLock.Lock()
+			transitions = append(transitions, event.Label+`:`+strings.TrimPrefix(event.Name(), `PROCESS_STATE_`))
+			transitionLock.Unlock()
+		}
+	})
+
+	tiers := priorityTiers(manager.Programs(), false)
+	assert.Len(tiers, 3)
+	assert.Equal(`database`, tiers[0][0].Name)
+	assert.Equal(`worker`, tiers[2][0].Name)
+
+	go manager.Run()
+	time.Sleep(4 * time.Second)
+	stopAndVerifyManager(manager, assert)
+	time.Sleep(100 * time.Millisecond)
+
+	transitionLock.Lock()
+	defer transitionLock.Unlock()
+
+	var before = func(a string, b string) {
+		ai, bi := slices.Index(transitions, a), slices.Index(transitions, b)
+		assert.True(ai >= 0 && bi >= 0 && ai < bi, "expected %s before %s in %v", a, b, transitions)
+	}
+
+	// each tier is running before the next one starts
+	before(`database:RUNNING`, `broker:STARTING`)
+	before(`broker:RUNNING`, `worker:STARTING`)
+
+	// and stopped in reverse
+	before(`worker:STOPPED`, `broker:STOPPING`)
+	before(`broker:STOPPED`, `database:STOPPING`)
+}
+
+func TestStopSignals(t *testing.T) {
+	assert := require.New(t)
+	t.Setenv(`PROCWATCH_TEST_LOGDIR`, t.TempDir())
+
+	manager, err := newManager(`stopsignal`)
+	assert.NoError(err)
+
+	var stopped = make(map[string][]string)
+	var stoppedLock sync.Mutex
+
+	manager.AddEventHandler(func(event *Event) {
+		if event.HasName(`PROCESS_STATE_STOPPED`) {
+			stoppedLock.Lock()
+			stopped[event.Label] = event.Arguments
+			stoppedLock.Unlock()
+		}
+	})
+
+	trapper, ok := manager.Program(`trapper`)
+	assert.True(ok)
+
+	stubborn, ok := manager.Program(`stubborn`)
+	assert.True(ok)
+
+	go manager.Run()
+	time.Sleep(2 * time.Second)
+	assert.Equal(ProgramRunning, trapper.GetState())
+	assert.Equal(ProgramRunning, stubborn.GetState())
+
+	stopAndVerifyManager(manager, assert)
+	time.Sleep(100 * time.Millisecond)
+
+	assert.Equal(ProgramStopped, trapper.GetState())
+	assert.Equal(ProgramStopped, stubborn.GetState())
+
+	data, err := os.ReadFile(trapper.LogfilePath(true))
+	assert.NoError(err)
+	assert.Contains(string(data), "caught INT\n")
+
+	stoppedLock.Lock()
+	defer stoppedLock.Unlock()
+
+	// the trapper exits on its stop signal...
+	assert.Contains(stopped[`trapper`], `signal:INT`)
+	assert.Contains(stopped[`trapper`], `group:false`)
+
+	// ...but the stubborn one ignores it and has to be killed
+	assert.Contains(stopped[`stubborn`], `signal:KILL`)
+	assert.Contains(stopped[`stubborn`], `group:true`)
+}
+
+func TestUpdate(t *testing.T) {
+	assert := require.New(t)
+	config := filepath.Join(t.TempDir(), `procwatch.ini`)
+
+	assert.NoError(os.WriteFile(config, []byte(`
+[program:keep]
+command = sleep 30
+
+[program:change]
+command = sleep 30
+
+[program:drop]
+command = sleep 30
+
+[group:pair]
+programs = steady,edited
+
+[program:steady]
+command = sleep 30
+
+[program:edited]
+command = sleep 30
+`), 0644))
+
+	manager := NewManagerFromConfig(config)
+	manager.Server.Address = `:0`
+	assert.NoError(manager.Initialize())
+
+	go manager.Run()
+	time.Sleep(1500 * time.Millisecond)
+
+	keep, ok := manager.Program(`keep`)
+	assert.True(ok)
+	assert.Equal(ProgramRunning, keep.GetState())
+	keepPID := keep.PID()
+
+	steady, ok := manager.Program(`steady`)
+	assert.True(ok)
+	assert.Equal(ProgramRunning, steady.GetState())
+	steadyPID := steady.PID()
+
+	assert.NoError(os.WriteFile(config, []byte(`
+[program:keep]
+command = sleep 30
+
+[program:change]
+command = sleep 31
+
+[program:add]
+command = sleep 30
+
+[group:pair]
+programs = steady,edited
+
+[program:steady]
+command = sleep 30
+
+[program:edited]
+command = sleep 31
+`), 0644))
+
+	diff, err := manager.Reread()
+	assert.NoError(err)
+	assert.Equal([]string{`add`}, diff.Added)
+	assert.ElementsMatch([]string{`change`, `pair`}, diff.Changed)
+	assert.Equal([]string{`drop`}, diff.Removed)
+
+	// a reread alone doesn't change anything
+	_, ok = manager.Program(`drop`)
+	assert.True(ok)
+
+	diff, err = manager.Update()
+	assert.NoError(err)
+	assert.Equal([]string{`add`}, diff.Added)
+
+	// new and changed programs are running by the time the update returns
+	_, ok = manager.Program(`drop`)
+	assert.False(ok)
+
+	for _, name := range []string{`add`, `change`, `edited`} {
+		program, ok := manager.Program(name)
+		assert.True(ok)
+		assert.Equal(ProgramRunning, program.GetState(), name)
+	}
+
+	change, _ := manager.Program(`change`)
+	assert.Equal(`sleep 31`, change.CommandString)
+
+	// unchanged programs are left running
+	same, ok := manager.Program(`keep`)
+	assert.True(ok)
+	assert.Same(keep, same)
+	assert.Equal(keepPID, same.PID())
+
+	// ...even when another program in their group changed
+	same, ok = manager.Program(`steady`)
+	assert.True(ok)
+	assert.Same(steady, same)
+	assert.Equal(steadyPID, same.PID())
+
+	edited, _ := manager.Program(`edited`)
+	assert.Equal(`sleep 31`, edited.CommandString)
+
+	diff, err = manager.Update()
+	assert.NoError(err)
+	assert.Empty(diff.Added)
+	assert.Empty(diff.Changed)
+	assert.Empty(diff.Removed)
+
+	stopAndVerifyManager(manager, assert)
+}
+
+func TestForwardSignals(t *testing.T) {
+	assert := require.New(t)
+	t.Setenv(`PROCWATCH_TEST_LOGDIR`, t.TempDir())
+
+	manager, err := newManager(`forward`)
+	assert.NoError(err)
+
+	forwarded, ok := manager.Program(`forwarded`)
+	assert.True(ok)
+	assert.Equal([]string{`HUP`, `SIGUSR2`}, forwarded.ForwardSignals)
+
+	ignored, ok := manager.Program(`ignored`)
+	assert.True(ok)
+
+	go manager.Run()
+	time.Sleep(1500 * time.Millisecond)
+
+	manager.ForwardSignal(SIGHUP)
+	manager.ForwardSignal(SIGUSR1)
+	time.Sleep(500 * time.Millisecond)
+
+	// move the log aside and have it reopened, as logrotate would
+	logfile := forwarded.LogfilePath(true)
+	assert.NoError(os.Rename(logfile, logfile+`.1`))
+	assert.NoError(manager.ReopenLogs())
+
+	manager.ForwardSignal(SIGHUP)
+	time.Sleep(500 * time.Millisecond)
+	stopAndVerifyManager(manager, assert)
+	time.Sleep(100 * time.Millisecond)
+
+	data, err := os.ReadFile(logfile + `.1`)
+	assert.NoError(err)
+	assert.Contains(string(data), "ready\n")
+	assert.Contains(string(data), "got HUP\n")
+	assert.NotContains(string(data), "got USR1\n")
+
+	data, err = os.ReadFile(logfile)
+	assert.NoError(err)
+	assert.NotContains(string(data), "ready\n")
+	assert.Contains(string(data), "got HUP\n")
+
+	data, err = os.ReadFile(ignored.LogfilePath(true))
+	assert.NoError(err)
+	assert.Contains(string(data), "ready\n")
+	assert.NotContains(string(data), "got")
+}
@@ -1,9 +1,11 @@
 package procwatch
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"sync"
 	"syscall"
@@ -27,6 +29,9 @@ import (
 
 const MaxProcessKillWaitTime = (5 * time.Second)
 const ProcessStateSettleInterval = (250 * time.Millisecond)
+const DefaultProcessName = `%(program_name)s`
+
+var ErrNotRunning = errors.New(`program is not running`)
 
 type ProgramState string
 
@@ -44,44 +49,87 @@ const (
 type ProgramSignal string
 
 const (
-	SIGKILL ProgramSignal = `KILL`
-	SIGINT  ProgramSignal = `INT`
-	SIGTERM ProgramSignal = `TERM`
-	SIGHUP  ProgramSignal = `HUP`
-	SIGQUIT ProgramSignal = `QUIT`
-	SIGUSR1 ProgramSignal = `USR1`
-	SIGUSR2 ProgramSignal = `USR2`
+	SIGKILL  ProgramSignal = `KILL`
+	SIGINT   ProgramSignal = `INT`
+	SIGTERM  ProgramSignal = `TERM`
+	SIGHUP   ProgramSignal = `HUP`
+	SIGQUIT  ProgramSignal = `QUIT`
+	SIGUSR1  ProgramSignal = `USR1`
+	SIGUSR2  ProgramSignal = `USR2`
+	SIGABRT  ProgramSignal = `ABRT`
+	SIGALRM  ProgramSignal = `ALRM`
+	SIGCONT  ProgramSignal = `CONT`
+	SIGSTOP  ProgramSignal = `STOP`
+	SIGTSTP  ProgramSignal = `TSTP`
+	SIGTTIN  ProgramSignal = `TTIN`
+	SIGTTOU  ProgramSignal = `TTOU`
+	SIGWINCH ProgramSignal = `WINCH`
 )
 
+var programSignals = map[ProgramSignal]syscall.Signal{
+	SIGKILL:  syscall.SIGKILL,
+	SIGINT:   syscall.SIGINT,
+	SIGTERM:  syscall.SIGTERM,
+	SIGHUP:   syscall.SIGHUP,
+	SIGQUIT:  syscall.SIGQUIT,
+	SIGUSR1:  syscall.SIGUSR1,
+	SIGUSR2:  syscall.SIGUSR2,
+	SIGABRT:  syscall.SIGABRT,
+	SIGALRM:  syscall.SIGALRM,
+	SIGCONT:  syscall.SIGCONT,
+	SIGSTOP:  syscall.SIGSTOP,
+	SIGTSTP:  syscall.SIGTSTP,
+	SIGTTIN:  syscall.SIGTTIN,
+	SIGTTOU:  syscall.SIGTTOU,
+	SIGWINCH: syscall.SIGWINCH,
+}
+
+// Returns the OS signal for the given signal name or number.  Unrecognized signals are
+// treated as SIGKILL.
 func (signal ProgramSignal) Signal() os.Signal {
-	switch signal {
-	case SIGINT:
-		return os.Interrupt
-	case SIGTERM:
-		return syscall.SIGTERM
-	case SIGHUP:
-		return syscall.SIGHUP
-	case SIGQUIT:
-		return syscall.SIGQUIT
-	case SIGUSR1:
-		return syscall.SIGUSR1
-	case SIGUSR2:
-		return syscall.SIGUSR2
-	default:
+	if sig, ok := programSignals[signal]; ok {
+		return sig
+	} else if n, err := strconv.Atoi(string(signal)); err == nil && n > 0 {
+		return syscall.Signal(n)
+	} else {
 		return os.Kill
 	}
 }
 
+// Parses a signal name, with or without the "SIG" prefix, or a signal number into a
+// ProgramSignal.  Numbers of signals that have a name are returned as that name.
+func ParseProgramSignal(name string) (ProgramSignal, error) {
+	var signal = ProgramSignal(strings.TrimPrefix(strings.ToUpper(strings.TrimSpace(name)), `SIG`))
+
+	if _, ok := programSignals[signal]; ok {
+		return signal, nil
+	} else if n, err := strconv.Atoi(string(signal)); err == nil && n > 0 && n <= 64 {
+		for named, sig := range programSignals {
+			if int(sig) == n {
+				return named, nil
+			}
+		}
+
+		return ProgramSignal(strconv.Itoa(n)), nil
+	}
+
+	return ``, fmt.Errorf("unknown signal %q", name)
+}
+
 type Program struct {
 	Name                  string        `json:"name"                              ini:"-"`
+	ProgramName           string        `json:"program"                           ini:"-"`
+	Group                 string        `json:"group"                             ini:"-"`
+	ProcessNum            int           `json:"process_num"                       ini:"-"`
 	LoadIndex             int           `json:"index"                             ini:"-"`
 	State                 ProgramState  `json:"state"                             ini:"-"`
 	ProcessID             int           `json:"pid"                               ini:"-"`
 	Command               any           `json:"command"                           ini:"-"`
 	ProcessName           string        `json:"process_name,omitempty"            ini:"process_name,omitempty"`
 	NumProcs              int           `json:"numprocs,omitempty"                ini:"numprocs,omitempty"`
+	NumProcsStart         int           `json:"numprocs_start,omitempty"          ini:"numprocs_start,omitempty"`
 	Directory             string        `json:"directory,omitempty"               ini:"directory,omitempty"`
-	UMask                 int           `json:"umask,omitempty"                   ini:"umask,omitempty"`
+	UMask                 int           `json:"-"                                 ini:"-"`
 	Priority              int           `json:"priority,omitempty"                ini:"priority,omitempty"`
 	AutoStart             bool          `json:"autostart,omitempty"               ini:"autostart,omitempty"`
 	AutoRestart           string        `json:"autorestart,omitempty"             ini:"autorestart,omitempty"`
@@ -92,6 +140,8 @@ type Program struct {
 	StopWaitSeconds       int           `json:"stopwaitsecs,omitempty"            ini:"stopwaitsecs,omitempty"`
 	StopAsGroup           bool          `json:"stopasgroup,omitempty"             ini:"stopasgroup,omitempty"`
 	KillAsGroup           bool          `json:"killasgroup,omitempty"             ini:"killasgroup,omitempty"`
+	ForwardSignals        []string      `json:"forward_signals,omitempty"         delim:"," ini:"forward_signals,omitempty"`
+	Stdin                 string        `json:"stdin,omitempty"                   ini:"stdin,omitempty"`
 	User                  string        `json:"user,omitempty"                    ini:"user,omitempty"`
 	RedirectStderr        bool          `json:"redirect_stderr,omitempty"         ini:"redirect_stderr,omitempty"`
 	StdoutLogfile         string        `json:"stdout_logfile,omitempty"          ini:"stdout_logfile,omitempty"`
@@ -104,11 +154,19 @@ type Program struct {
 	StderrLogfileBackups  int           `json:"stderr_logfile_backups,omitempty"  ini:"stderr_logfile_backups,omitempty"`
 	StderrCaptureMaxBytes string        `json:"stderr_capture_maxbytes,omitempty" ini:"stderr_capture_maxbytes,omitempty"`
 	StderrEventsEnabled   bool          `json:"stderr_events_enabled,omitempty"   ini:"stderr_events_enabled,omitempty"`
-	Environment           []string      `json:"environment,omitempty"             delim:"," ini:"environment,omitempty"`
+	Environment           []string      `json:"environment,omitempty"             ini:"-"`
+	EnvFiles              []string      `json:"env_file,omitempty"                delim:"," ini:"env_file,omitempty"`
+	ClearEnv              bool          `json:"clearenv,omitempty"                ini:"clearenv,omitempty"`
 	ServerUrl             string        `json:"serverurl,omitempty"               ini:"serverurl,omitempty"`
 	Schedule              string        `json:"schedule,omitempty"                ini:"schedule,omitempty"`
+	EventListener         bool          `json:"eventlistener,omitempty"           ini:"-"`
+	Events                []string      `json:"events,omitempty"                  delim:"," ini:"events,omitempty"`
+	BufferSize            int           `json:"buffer_size,omitempty"             ini:"buffer_size,omitempty"`
 	CommandString         string        `json:"-"                                 ini:"command"`
+	EnvironmentString     string        `json:"-"                                 ini:"environment,omitempty"`
+	UMaskString           string        `json:"umask,omitempty"                   ini:"umask,omitempty"`
 	LastExitStatus        int           `json:"last_exit_status,omitempty"        ini:"-"`
+	SpawnError            string        `json:"spawnerr,omitempty"                ini:"-"`
 	LastStartedAt         time.Time     `json:"last_started_at,omitempty"         ini:"-"`
 	LastExitedAt          time.Time     `json:"last_exited_at,omitempty"          ini:"-"`
 	LastTriggeredAt       time.Time     `json:"last_triggered_at,omitempty"       ini:"-"`
@@ -118,7 +176,14 @@ type Program struct {
 	cmd                   *cmd.Cmd
 	hasEverBeenStarted    bool
 	processLock           sync.Mutex
-	rollingLogger         *lumberjack.Logger
+	stopRequested         bool
+	lastStopSignal        ProgramSignal
+	lastStopAsGroup       bool
+	stdoutLogger          *lumberjack.Logger
+	stderrLogger          *lumberjack.Logger
+	listener              *eventListener
+	stdin                 *os.File
+	stdinLock             sync.Mutex
 }
 
 func LoadProgramsFromConfig(data []byte, manager *Manager) (map[string]*Program, error) {
@@ -126,19 +191,93 @@ func LoadProgramsFromConfig(data []byte, manager *Manager) (map[string]*Program,
 
 	if iniFile, err := ini.Load(data); err == nil {
 		for _, section := range iniFile.Sections() {
-			if strings.HasPrefix(section.Name(), `program:`) {
-				var _, name = stringutil.SplitPair(section.Name(), `:`)
-				var program = new(Program)
+			var kind, name = stringutil.SplitPair(section.Name(), `:`)
+
+			// event listeners are programs that additionally speak the event listener protocol
+			if kind == `program` || kind == `eventlistener` {
+				var template = new(Program)
+
+				if err := section.MapTo(template); err != nil {
+					return nil, fmt.Errorf("%v:%v: %v", kind, name, err)
+				}
+
+				var group *Group
+
+				if g, err := manager.groupFor(name); err == nil {
+					group = g
+				} else {
+					return nil, fmt.Errorf("%v:%v: %v", kind, name, err)
+				}
+
+				var numprocs = int(typeutil.OrInt(template.NumProcs, 1))
+				var processName = typeutil.OrString(template.ProcessName, DefaultProcessName)
+
+				if numprocs > 1 && !strings.Contains(processName, `%(process_num)`) {
+					return nil, fmt.Errorf("%v:%v: process_name must include %%(process_num) when numprocs > 1", kind, name)
+				}
+
+				if template.Stdin != `` && template.Stdin != StdinPipe {
+					return nil, fmt.Errorf("%v:%v: stdin must be %q if set", kind, name, StdinPipe)
+				}
+
+				for label, value := range map[string]string{
+					`stdout_capture_maxbytes`: template.StdoutCaptureMaxBytes,
+					`stderr_capture_maxbytes`: template.StderrCaptureMaxBytes,
+				} {
+					if value != `` {
+						if _, err := humanize.ParseBytes(value); err != nil {
+							return nil, fmt.Errorf("%v:%v: %s: %v", kind, name, label, err)
+						}
+					}
+				}
+
+				// each of the numprocs instances is mapped from the section separately so that
+				// they are fully independent of one another
+				for i := 0; i < numprocs; i++ {
+					var program = new(Program)
+					var num = template.NumProcsStart + i
+
+					if err := section.MapTo(program); err != nil {
+						return nil, fmt.Errorf("%v:%v: %v", kind, name, err)
+					}
+
+					if instanceName, err := Interpolate(processName, manager.interpolationVars(map[string]any{
+						`program_name`: name,
+						`process_num`:  num,
+						`group_name`:   group.Name,
+					})); err == nil {
+						program.Name = instanceName
+					} else {
+						return nil, fmt.Errorf("%v:%v: process_name: %v", kind, name, err)
+					}
 
-				if err := section.MapTo(program); err == nil {
-					program.Name = name
+					program.ProgramName = name
+					program.ProcessNum = num
+					program.EventListener = (kind == `eventlistener`)
 					program.Command = program.CommandString
 
+					// umask is octal whether or not it has a leading zero, and 000 is a valid one
+					if program.UMaskString != `` {
+						if umask, err := strconv.ParseUint(program.UMaskString, 8, 32); err == nil {
+							program.UMask = int(umask)
+						} else {
+							return nil, fmt.Errorf("%v:%v: umask: %v", kind, name, err)
+						}
+					}
+
+					if env, err := ParseEnvironment(program.EnvironmentString); err == nil {
+						program.Environment = env
+					} else {
+						return nil, fmt.Errorf("%v:%v: environment: %v", kind, name, err)
+					}
+
+					if _, ok := manager.Program(program.Name); ok {
+						return nil, fmt.Errorf("%v:%v: duplicate process name %q", kind, name, program.Name)
+					}
+
 					if err := manager.AddProgram(program); err != nil {
-						return nil, fmt.Errorf("program:%v: %v", name, err)
+						return nil, fmt.Errorf("%v:%v: %v", kind, name, err)
 					}
-				} else {
-					return nil, fmt.Errorf("program:%v: %v", name, err)
 				}
 			}
 		}
@@ -153,7 +292,8 @@ func NewProgram(name string, manager *Manager) *Program {
 	return &Program{
 		Name:                  name,
 		State:                 ProgramStopped,
-		ProcessName:           `%(program_name)s`,
+		ProgramName:           name,
+		ProcessName:           DefaultProcessName,
 		NumProcs:              1,
 		Priority:              999,
 		AutoStart:             true,
@@ -196,6 +336,16 @@ func (program *Program) String() string {
 	return ``
 }
 
+// Returns the name used to refer to this program from the outside.  Processes whose name
+// differs from that of their group are named "group:process", all others by name alone.
+func (program *Program) FullName() string {
+	if program.Group == `` || program.Group == program.Name {
+		return program.Name
+	} else {
+		return program.Group + `:` + program.Name
+	}
+}
+
 func (program *Program) detectLevel(line string) log.Level {
 	var iline = strings.ToLower(line)
 
@@ -213,10 +363,75 @@ func (program *Program) detectLevel(line string) log.Level {
 func (program *Program) Log(line string, stdout bool) {
 	log.Logf(program.detectLevel(line), "[%s] \u25b8  %s", program.Name, line)
 
+	var logfile = program.LogfilePath(stdout)
+
+	switch strings.ToLower(logfile) {
+	case `none`:
+		return
+	case `stdout`:
+		fmt.Fprint(os.Stdout, strings.TrimSuffix(line, "\n")+"\n")
+	case `stderr`:
+		fmt.Fprint(os.Stderr, strings.TrimSuffix(line, "\n")+"\n")
+	default:
+		fmt.Fprintf(
+			program.logger(logfile, program.logsToStdout(stdout)),
+			"%s %s\n",
+			time.Now().Format(`2006-01-02 15:04:05,999`),
+			line,
+		)
+	}
+}
+
+// Logs a line of a process' output, less anything taken by capture mode, and emits the events
+// it calls for.
+func (program *Program) handleOutput(process *cmd.Cmd, capture *outputCapture, stdout bool, line string) {
+	var channel = program.logsToStdout(stdout)
+
+	if capture != nil {
+		var loggable bool
+		var captured []string
+
+		line, loggable, captured = capture.filter(line)
+
+		for _, data := range captured {
+			program.manager.pushProcessCommunicationEvent(program, process.Status().PID, channel, data)
+		}
+
+		if !loggable {
+			return
+		}
+	}
+
+	for _, ln := range strings.Split(line, "\n") {
+		program.Log(ln, stdout)
+	}
+
+	program.logEvent(process, channel, line)
+}
+
+// Emits a PROCESS_LOG event for output the program wrote, if stdout_events_enabled (or
+// stderr_events_enabled) is set.
+func (program *Program) logEvent(process *cmd.Cmd, stdout bool, data string) {
+	if stdout && !program.StdoutEventsEnabled {
+		return
+	} else if !stdout && !program.StderrEventsEnabled {
+		return
+	}
+
+	program.manager.pushProcessLogEvent(program, process.Status().PID, stdout, data)
+}
+
+func (program *Program) logsToStdout(stdout bool) bool {
+	return stdout || program.RedirectStderr || program.manager.RedirectStderr
+}
+
+// Returns the file that the program's standard output (or standard error) is written to.
+// This is either a path or one of "none", "stdout", or "stderr".
+func (program *Program) LogfilePath(stdout bool) string {
 	var logfile string
 	var suffix string
 
-	if stdout || program.RedirectStderr || program.manager.RedirectStderr {
+	if program.logsToStdout(stdout) {
 		logfile = program.StdoutLogfile
 
 		if program.RedirectStderr {
@@ -230,61 +445,62 @@ func (program *Program) Log(line string, stdout bool) {
 	}
 
 	if logfile == `AUTO` {
-		// TODO: this should be ProcessName, but has to wait until pattern interpolation is built
 		logfile = filepath.Join(program.manager.ChildLogDir, fmt.Sprintf("%s%s", program.Name, suffix))
 	}
 
-	logfile = fileutil.MustExpandUser(logfile)
+	return fileutil.MustExpandUser(logfile)
+}
 
-	switch strings.ToLower(logfile) {
-	case `none`:
-		return
-	case `stdout`:
-		fmt.Fprint(os.Stdout, strings.TrimSuffix(line, "\n")+"\n")
-	case `stderr`:
-		fmt.Fprint(os.Stderr, strings.TrimSuffix(line, "\n")+"\n")
-	default:
-		if program.rollingLogger == nil {
-			var maxsize int
-			var backups int
+// Returns the rolling logger for the given stream, creating it if necessary.
+func (program *Program) logger(logfile string, stdout bool) *lumberjack.Logger {
+	program.processLock.Lock()
+	defer program.processLock.Unlock()
 
-			if stdout || program.RedirectStderr {
-				backups = program.StdoutLogfileBackups
+	var logger = &program.stderrLogger
+	var maxbytes = program.StderrLogfileMaxBytes
+	var backups = program.StderrLogfileBackups
 
-				if b, err := humanize.ParseBytes(program.StdoutLogfileMaxBytes); err == nil {
-					maxsize = int(b)
-				} else {
-					maxsize = int(DefaultLogFileMaxBytes)
-				}
-			} else {
-				backups = program.StderrLogfileBackups
+	if stdout {
+		logger = &program.stdoutLogger
+		maxbytes = program.StdoutLogfileMaxBytes
+		backups = program.StdoutLogfileBackups
+	}
 
-				if b, err := humanize.ParseBytes(program.StderrLogfileMaxBytes); err == nil {
-					maxsize = int(b)
-				} else {
-					maxsize = int(DefaultLogFileMaxBytes)
-				}
-			}
+	if *logger == nil {
+		var maxsize int
 
-			program.rollingLogger = &lumberjack.Logger{
-				Filename:   logfile,
-				MaxSize:    int(mathutil.ClampLower(float64(maxsize/1048576), 1)),
-				MaxBackups: backups,
-				Compress:   true,
-			}
+		if b, err := humanize.ParseBytes(maxbytes); err == nil {
+			maxsize = int(b)
+		} else {
+			maxsize = int(DefaultLogFileMaxBytes)
+		}
 
-			if parent := filepath.Dir(logfile); !fileutil.DirExists(parent) {
-				os.MkdirAll(parent, 0700)
-			}
+		*logger = &lumberjack.Logger{
+			Filename:   logfile,
+			MaxSize:    int(mathutil.ClampLower(float64(maxsize/1048576), 1)),
+			MaxBackups: backups,
+			Compress:   true,
 		}
 
-		fmt.Fprintf(
-			program.rollingLogger,
-			"%s %s\n",
-			time.Now().Format(`2006-01-02 15:04:05,999`),
-			line,
-		)
+		if parent := filepath.Dir(logfile); !fileutil.DirExists(parent) {
+			os.MkdirAll(parent, 0700)
+		}
+
+		var configured = program.StderrLogfile
+
+		if stdout {
+			configured = program.StdoutLogfile
+		}
+
+		// automatically-named logs belong to the user the program runs as
+		if configured == `AUTO` {
+			if err := program.chownLogfile(logfile); err != nil {
+				log.Warningf("[%s] failed to set owner of %s: %v", program.Name, logfile, err)
+			}
+		}
 	}
+
+	return *logger
 }
 
 func (program *Program) GetState() ProgramState {
@@ -371,16 +587,19 @@ func (program *Program) Start() int {
 
 		// if process started successfully and stayed running for program.StartSeconds
 		if err := program.startProcess(); err == nil {
+			program.SpawnError = ``
 			program.transitionTo(ProgramRunning)
 		} else {
 			log.Warningf("[%s] Failed to start: %v", program.Name, err)
 
 			program.killProcess(false)
 
-			if program.ShouldAutoRestart() {
-				program.transitionTo(ProgramBackoff)
+			program.SpawnError = err.Error()
+
+			if program.ShouldAutoRestart() && !isPermanentSpawnError(err) {
+				program.transitionWithError(ProgramBackoff, err)
 			} else {
-				program.transitionTo(ProgramFatal)
+				program.transitionWithError(ProgramFatal, err)
 			}
 
 			program.LastExitedAt = time.Now()
@@ -395,17 +614,35 @@ func (program *Program) Stop() {
 		ProgramStarting,
 		ProgramRunning,
 	) {
-		program.transitionTo(ProgramStopping)
+		program.beginStopping(false)
 		program.processRetryCount = 0
 		program.killProcess(false)
 	}
 }
 
 func (program *Program) ForceStop() {
-	program.transitionTo(ProgramStopping)
+	program.beginStopping(true)
 	program.killProcess(true)
 }
 
+// Records the signal that is about to be used to stop the program and moves it to STOPPING.
+func (program *Program) beginStopping(force bool) {
+	program.lastStopSignal, program.lastStopAsGroup = program.stopSignal(force)
+	program.transitionTo(ProgramStopping)
+}
+
+// Returns the signal used to stop the program, and whether it is sent to the whole process group.
+func (program *Program) stopSignal(force bool) (ProgramSignal, bool) {
+	if force {
+		return SIGKILL, program.KillAsGroup
+	} else if signal, err := ParseProgramSignal(string(program.StopSignal)); err == nil {
+		return signal, program.StopAsGroup
+	} else {
+		log.Warningf("[%s] Invalid stopsignal, using TERM: %v", program.Name, err)
+		return SIGTERM, program.StopAsGroup
+	}
+}
+
 func (program *Program) StopFatal() {
 	program.Stop()
 	program.transitionTo(ProgramFatal)
@@ -416,6 +653,39 @@ func (program *Program) Restart() {
 	program.Start()
 }
 
+// Sends the given signal to the program's process.
+func (program *Program) Signal(signal ProgramSignal) error {
+	return program.sendSignal(signal, false)
+}
+
+// Sends the given signal to every process in the program's process group.
+func (program *Program) SignalGroup(signal ProgramSignal) error {
+	return program.sendSignal(signal, true)
+}
+
+func (program *Program) sendSignal(signal ProgramSignal, asGroup bool) error {
+	var pid = program.PID()
+
+	if pid <= 0 {
+		return ErrNotRunning
+	}
+
+	var target = pid
+
+	if asGroup {
+		target = -pid
+	}
+
+	if sig, ok := signal.Signal().(syscall.Signal); !ok {
+		return fmt.Errorf("unsupported signal %v", signal)
+	} else if err := syscall.Kill(target, sig); err != nil {
+		return err
+	}
+
+	program.manager.pushProcessSignalEvent(program, pid, signal, asGroup)
+	return nil
+}
+
 func (program *Program) PID() int {
 	if !program.InState(ProgramStarting, ProgramRunning, ProgramStopping) {
 		return -1
@@ -445,14 +715,19 @@ func (program *Program) InTerminalState() bool {
 }
 
 func (program *Program) transitionTo(state ProgramState) {
-	if program.GetState() != state {
+	program.transitionWithError(state, nil)
+}
+
+// transitions to the given state, attaching err (if any) to the resulting event
+func (program *Program) transitionWithError(state ProgramState, err error) {
+	if from := program.GetState(); from != state {
 		switch state {
 		case ProgramBackoff:
 			program.processRetryCount += 1
 		}
 
 		program.State = state
-		program.manager.pushProcessStateEvent(state, program, nil)
+		program.manager.pushProcessStateEvent(from, state, program, err)
 	}
 }
 
@@ -478,15 +753,20 @@ func (program *Program) startProcess() error {
 	}
 
 	var words []string
+	var secrets secretRefs
 
+	// secret references are set aside so that neither word splitting nor variable expansion
+	// touches them (or the values they resolve to)
 	if typeutil.IsArray(program.Command) {
-		words = sliceutil.Stringify(program.Command)
+		for _, word := range sliceutil.Stringify(program.Command) {
+			words = append(words, secrets.protect(word))
+		}
 	} else {
 		var shwords = shellwords.NewParser()
 		shwords.ParseEnv = true
 		shwords.ParseBacktick = false
 
-		if w, err := shwords.Parse(typeutil.String(program.Command)); err == nil {
+		if w, err := shwords.Parse(secrets.protect(typeutil.String(program.Command))); err == nil {
 			words = w
 		} else {
 			return err
@@ -494,46 +774,139 @@ func (program *Program) startProcess() error {
 	}
 
 	if len(words) > 0 {
+		var values, err = secrets.resolve()
+
+		if err != nil {
+			return fmt.Errorf("command: %v", err)
+		}
+
+		// what gets logged has the secrets masked out
+		var masked = make([]string, len(words))
+
 		for i, word := range words {
 			// expand all tildes into the current user's home directory
 			words[i], _ = fileutil.ExpandUser(word)
 
 			// expand environment variables
 			words[i] = os.ExpandEnv(words[i])
+
+			masked[i] = secrets.mask(words[i])
+			words[i] = secrets.restore(words[i], values)
 		}
 
-		var cmd = cmd.NewCmdOptions(cmd.Options{
+		var options = cmd.Options{
 			Streaming: true,
-		}, words[0], words[1:]...)
+		}
+
+		credential, err := program.credential()
 
-		cmd.Env = program.getEnvironment()
-		cmd.Dir = fileutil.MustExpandUser(program.Directory)
+		if err != nil {
+			return err
+		} else if credential != nil {
+			options.BeforeExec = append(options.BeforeExec, withCredential(credential))
+		}
+
+		var dir = fileutil.MustExpandUser(program.Directory)
+
+		if dir != `` {
+			if err := checkDirectory(dir, credential); err != nil {
+				return err
+			}
+		}
+
+		if program.UMaskString != `` {
+			// the shell would report a missing command as an ordinary exit, which is retried
+			if err := checkCommand(words[0], dir); err != nil {
+				return err
+			}
+
+			words = umaskShim(program.UMask, words)
+		}
+
+		var cmd = cmd.NewCmdOptions(options, words[0], words[1:]...)
+
+		if env, err := program.getEnvironment(); err == nil {
+			cmd.Env = env
+		} else {
+			return err
+		}
+
+		cmd.Dir = dir
+
+		var listener = program.listener
 
 		go func() {
+			var capture = program.newOutputCapture(true)
+
 			for line := range cmd.Stdout {
-				for _, ln := range strings.Split(line, "\n") {
-					program.Log(ln, true)
+				// the stdout of event listeners carries the event listener protocol
+				if listener != nil {
+					listener.handleOutput(line)
+					continue
 				}
+
+				program.handleOutput(cmd, capture, true, line)
 			}
 		}()
 
 		go func() {
+			// like its log, stderr is treated as stdout when it's redirected there
+			var capture = program.newOutputCapture(program.logsToStdout(false))
+
 			for line := range cmd.Stderr {
-				for _, ln := range strings.Split(line, "\n") {
-					program.Log(ln, false)
-				}
+				program.handleOutput(cmd, capture, false, line)
 			}
 		}()
 
-		log.Debugf("[%s] command: %s", program.Name, executil.Join(words))
-		cmd.Start()
+		log.Debugf("[%s] command: %s", program.Name, executil.Join(masked))
 
-		if status := cmd.Status(); status.Error == nil {
+		var stdinR *os.File
+
+		if listener != nil {
+			// events are written to the listener's stdin
+			if r, stdinW, err := os.Pipe(); err == nil {
+				stdinR = r
+				listener.attach(stdinW)
+				cmd.StartWithStdin(stdinR)
+
+				go func() {
+					<-cmd.Done()
+					listener.detach()
+				}()
+			} else {
+				return err
+			}
+		} else if program.StdinEnabled() {
+			if r, stdinW, err := program.openStdin(); err == nil {
+				stdinR = r
+				cmd.StartWithStdin(stdinR)
+
+				go func() {
+					<-cmd.Done()
+					program.closeStdin(stdinW)
+				}()
+			} else {
+				return err
+			}
+		} else {
+			cmd.Start()
+		}
+
+		var status = waitForStart(cmd)
+
+		// the process has its own copy of the read end now; holding ours open would keep
+		// writes from ever seeing that it has closed its stdin
+		if stdinR != nil {
+			stdinR.Close()
+		}
+
+		if status.Error == nil {
 			// ---------------------------------------------------------------------
 			program.processLock.Lock()
 
 			program.cmd = cmd
 			program.ProcessID = status.PID
+			program.stopRequested = false
 			program.LastStartedAt = time.Now()
 
 			program.processLock.Unlock()
@@ -567,6 +940,22 @@ func (program *Program) startProcess() error {
 	}
 }
 
+// go-cmd starts the process in the background; this waits until it has either started
+// (and has a PID) or failed to.
+func waitForStart(process *cmd.Cmd) cmd.Status {
+	for {
+		if status := process.Status(); status.PID > 0 {
+			return status
+		}
+
+		select {
+		case <-process.Done():
+			return process.Status()
+		case <-time.After(5 * time.Millisecond):
+		}
+	}
+}
+
 func (program *Program) monitorProcess() {
 	program.processLock.Lock()
 	var process = program.cmd
@@ -585,7 +974,14 @@ func (program *Program) monitorProcess() {
 		// update the last known exit status
 		program.LastExitStatus = status.Exit
 
-		if program.IsExpectedStatus(program.LastExitStatus) {
+		program.processLock.Lock()
+		var stopRequested = program.stopRequested
+		program.processLock.Unlock()
+
+		if stopRequested {
+			// stopped on purpose; killProcess takes care of the state transition
+			program.LastExitedAt = time.Now()
+		} else if program.IsExpectedStatus(program.LastExitStatus) {
 			// if the code is an expected one, EXITED
 			program.LastExitedAt = time.Now()
 			program.transitionTo(ProgramExited)
@@ -605,39 +1001,53 @@ func (program *Program) monitorProcess() {
 	}
 }
 
+// Stops the program's process by sending it the configured stop signal (or SIGKILL if force
+// is true).  If the process hasn't exited after stopwaitsecs, it is sent SIGKILL.
 func (program *Program) killProcess(force bool) error {
 	if program.InState(ProgramStarting, ProgramRunning, ProgramStopping) {
 		program.processLock.Lock()
 		var process = program.cmd
+
+		// tell monitorProcess that this exit was requested, so it doesn't treat it as a crash
+		if process != nil {
+			program.stopRequested = true
+		}
+
 		program.processLock.Unlock()
 
 		if process != nil {
-			var status = process.Status()
-			log.Debugf("[%s] Stopping PID %d with", program.Name, status.PID)
+			var pid = process.Status().PID
+			var signal, asGroup = program.stopSignal(force)
+			var timeout = time.Duration(program.StopWaitSeconds) * time.Second
 
-			if err := process.Stop(); err == nil {
-				select {
-				case <-process.Done():
-					if wait := process.Status(); wait.Error != nil {
-						if force {
-							program.transitionTo(ProgramFatal)
-						} else {
-							program.transitionTo(ProgramStopped)
-						}
+			if err := program.sendStopSignal(pid, signal, asGroup); err != nil {
+				return err
+			}
 
-						return err
-					}
+			select {
+			case <-process.Done():
+			case <-time.After(timeout):
+				if force {
+					return fmt.Errorf("[%s] SIGKILL not handled", program.Name)
+				}
 
-				case <-time.After(time.Duration(program.StopWaitSeconds) * time.Second):
-					if !force {
-						log.Warningf("[%s] Signal not handled in time, sending SIGKILL", program.Name)
-						return program.killProcess(true)
-					} else {
-						return fmt.Errorf("[%s] SIGKILL not handled", program.Name)
-					}
+				log.Warningf("[%s] SIG%s not handled in time, sending SIGKILL", program.Name, signal)
+
+				if err := program.sendStopSignal(pid, SIGKILL, program.KillAsGroup); err != nil {
+					return err
 				}
+
+				select {
+				case <-process.Done():
+				case <-time.After(timeout):
+					return fmt.Errorf("[%s] SIGKILL not handled", program.Name)
+				}
+			}
+
+			if force {
+				program.transitionTo(ProgramFatal)
 			} else {
-				return err
+				program.transitionTo(ProgramStopped)
 			}
 		}
 	}
@@ -645,6 +1055,114 @@ func (program *Program) killProcess(force bool) error {
 	return nil
 }
 
-func (program *Program) getEnvironment() []string {
-	return append(os.Environ(), program.Environment...)
+// Sends a signal to the given PID, or to its whole process group if asGroup is true.  The
+// signal is recorded so that the stop events can report what was sent.
+func (program *Program) sendStopSignal(pid int, signal ProgramSignal, asGroup bool) error {
+	var target = pid
+
+	program.lastStopSignal = signal
+	program.lastStopAsGroup = asGroup
+
+	if asGroup {
+		// programs are started in their own process group, whose ID is the same as the PID
+		target = -pid
+	}
+
+	log.Debugf("[%s] Sending SIG%s to PID %d (group: %v)", program.Name, signal, pid, asGroup)
+
+	if sig, ok := signal.Signal().(syscall.Signal); !ok {
+		return fmt.Errorf("unsupported signal %v", signal)
+	} else if err := syscall.Kill(target, sig); err != nil && !errors.Is(err, syscall.ESRCH) {
+		return err
+	}
+
+	return nil
+}
+
+// Returns the environment the program's process is started with.  Later entries take
+// precedence: the environment procwatch was started with (unless clearenv is set), the
+// variables describing the program and procwatch, the manager's default environment, the
+// HOME/USER/LOGNAME of the user the program runs as, each env_file in order, and finally the
+// program's own environment.  Secret references in the manager's and program's environment
+// are resolved.
+func (program *Program) getEnvironment() ([]string, error) {
+	var env = make([]string, 0)
+
+	if !program.ClearEnv {
+		env = append(env, os.Environ()...)
+	}
+
+	env = append(env, program.procwatchEnvironment()...)
+
+	if program.manager != nil {
+		if pairs, err := resolveEnvironmentSecrets(program.manager.Environment); err == nil {
+			env = append(env, pairs...)
+		} else {
+			return nil, fmt.Errorf("environment: %v", err)
+		}
+	}
+
+	env = append(env, program.userEnvironment()...)
+
+	// env files are read at every start so that changes to them don't require a reload
+	for _, filename := range program.EnvFiles {
+		if pairs, err := LoadEnvFile(fileutil.MustExpandUser(filename)); err == nil {
+			env = append(env, pairs...)
+		} else {
+			return nil, fmt.Errorf("env_file: %v", err)
+		}
+	}
+
+	if pairs, err := resolveEnvironmentSecrets(program.Environment); err == nil {
+		return append(env, pairs...), nil
+	} else {
+		return nil, fmt.Errorf("environment: %v", err)
+	}
+}
+
+// Returns the variables that tell a program's process who it is and where to reach procwatch:
+// the SUPERVISOR_* variables Supervisor sets, along with PROCWATCH_PROGRAM (the name the API
+// knows the program by), PROCWATCH_INSTANCE (its process_num), and PROCWATCH_API (an address
+// the client package can connect to).
+func (program *Program) procwatchEnvironment() []string {
+	var env = []string{
+		`SUPERVISOR_ENABLED=1`,
+		`SUPERVISOR_PROCESS_NAME=` + program.Name,
+		`SUPERVISOR_GROUP_NAME=` + program.Group,
+		`PROCWATCH_PROGRAM=` + program.FullName(),
+		`PROCWATCH_INSTANCE=` + strconv.Itoa(program.ProcessNum),
+	}
+
+	if url := program.serverURL(); url != `` {
+		env = append(env, `SUPERVISOR_SERVER_URL=`+url, `PROCWATCH_API=`+url)
+	}
+
+	return env
+}
+
+// Returns the program's serverurl, where serverurl=AUTO (the default) is the address of the
+// manager's API server.
+func (program *Program) serverURL() string {
+	if program.ServerUrl != `` && program.ServerUrl != `AUTO` {
+		return program.ServerUrl
+	} else if program.manager != nil && program.manager.Server != nil {
+		return program.manager.Server.URL()
+	}
+
+	return ``
+}
+
+// Returns a copy of the given KEY=VALUE pairs with any secret references resolved.
+func resolveEnvironmentSecrets(pairs []string) ([]string, error) {
+	var resolved = make([]string, len(pairs))
+
+	for i, pair := range pairs {
+		if value, err := ResolveSecrets(pair); err == nil {
+			resolved[i] = value
+		} else {
+			return nil, err
+		}
+	}
+
+	return resolved, nil
 }
@@ -1,7 +1,12 @@
 package procwatch
 
 import (
+	"fmt"
+	"os"
+	"os/user"
 	"path"
+	"path/filepath"
+	"syscall"
 	"testing"
 	"time"
 
@@ -47,11 +52,20 @@ func newManager(config string) (*Manager, error) {
 func stopAndVerifyManager(manager *Manager, assert *require.Assertions) {
 	manager.Stop(false)
 
-	for _, program := range manager.programs {
+	for _, program := range manager.Programs() {
 		assert.True(program.InTerminalState())
 	}
 }
 
+// Waits for each of the given programs to reach the given state.
+func waitForState(assert *require.Assertions, state ProgramState, programs ...*Program) {
+	for _, program := range programs {
+		assert.Eventually(func() bool {
+			return program.InState(state)
+		}, 5*time.Second, 10*time.Millisecond, "%s never reached %s", program.Name, state)
+	}
+}
+
 func TestSuccessfulProgramLifecycle(t *testing.T) {
 	assert := require.New(t)
 	actualStates = nil
@@ -143,3 +157,349 @@ func TestFatalAutorestartProgramLifecycle(t *testing.T) {
 		ProgramFatal,
 	}, actualStates)
 }
+
+func TestNumProcsInstances(t *testing.T) {
+	assert := require.New(t)
+
+	manager, err := newManager(`numprocs`)
+	assert.NoError(err)
+	assert.Len(manager.Programs(), 3)
+
+	for i, name := range []string{`worker_00`, `worker_01`, `worker_02`} {
+		program, ok := manager.Program(name)
+		assert.True(ok)
+		assert.Equal(`worker`, program.ProgramName)
+		assert.Equal(i, program.ProcessNum)
+		assert.Equal(`worker:`+name, program.FullName())
+
+		byFullName, ok := manager.Program(`worker:` + name)
+		assert.True(ok)
+		assert.Equal(program, byFullName)
+	}
+
+	assert.Len(manager.ProgramInstances(`worker`), 3)
+}
+
+func TestProgramConfigInterpolation(t *testing.T) {
+	assert := require.New(t)
+	t.Setenv(`PROCWATCH_TEST_LOGDIR`, `/tmp/procwatch-test`)
+
+	manager, err := newManager(`interpolation`)
+	assert.NoError(err)
+
+	here, err := filepath.Abs(`./tests`)
+	assert.NoError(err)
+
+	hostname, err := os.Hostname()
+	assert.NoError(err)
+
+	program, ok := manager.Program(`interp`)
+	assert.True(ok)
+	assert.Equal(`./bin/procwatch-tester -p `+here+`/interp.pid`, program.Command)
+	assert.Equal(here, program.Directory)
+	assert.Equal(`/tmp/procwatch-test/interp_0.log`, program.StdoutLogfile)
+	assert.Equal([]string{`NODE=` + hostname}, program.Environment)
+}
+
+func TestGroups(t *testing.T) {
+	assert := require.New(t)
+
+	manager, err := newManager(`groups`)
+	assert.NoError(err)
+	assert.Len(manager.Groups(), 2)
+
+	web, ok := manager.Group(`web`)
+	assert.True(ok)
+	assert.False(web.Implicit)
+	assert.Equal(100, web.Priority)
+	assert.Len(web.Members(), 3)
+
+	solo, ok := manager.Group(`solo`)
+	assert.True(ok)
+	assert.True(solo.Implicit)
+	assert.Len(solo.Members(), 1)
+
+	program, ok := manager.Program(`web:app`)
+	assert.True(ok)
+	assert.Equal(`web:app`, program.FullName())
+
+	program, ok = manager.Program(`web:web_api_1`)
+	assert.True(ok)
+	assert.Equal(`api`, program.ProgramName)
+
+	program, ok = manager.Program(`solo`)
+	assert.True(ok)
+	assert.Equal(`solo`, program.FullName())
+
+	members, err := manager.Resolve(`web:*`)
+	assert.NoError(err)
+	assert.Len(members, 3)
+
+	members, err = manager.Resolve(`api`)
+	assert.NoError(err)
+	assert.Len(members, 2)
+}
+
+func TestGroupsFromIncludes(t *testing.T) {
+	assert := require.New(t)
+
+	manager, err := newManager(`include`)
+	assert.NoError(err)
+
+	web, ok := manager.Group(`web`)
+	assert.True(ok)
+	assert.Len(web.Members(), 2)
+
+	app, ok := manager.Program(`web:app`)
+	assert.True(ok)
+	assert.Equal(`web`, app.Group)
+
+	_, ok = manager.Group(`app`)
+	assert.False(ok)
+
+	// each program is interpolated relative to the file it came from
+	here, err := filepath.Abs(`./tests/include.d`)
+	assert.NoError(err)
+
+	api, ok := manager.Program(`web:api`)
+	assert.True(ok)
+	assert.Equal(here, api.Directory)
+
+	// the group's priority comes before that of its members
+	tiers := priorityTiers(manager.Programs(), false)
+	assert.Len(tiers, 3)
+	assert.Equal(`worker`, tiers[0][0].Name)
+	assert.Equal(`app`, tiers[1][0].Name)
+	assert.Equal(`api`, tiers[2][0].Name)
+
+	// a program can only be in one group
+	assert.ErrorContains(manager.AddGroup(&Group{
+		Name:     `other`,
+		Programs: []string{`worker`, `app`},
+	}), `already in group "web"`)
+
+	_, err = manager.Resolve(`nope`)
+	assert.Error(err)
+}
+
+func TestEventListener(t *testing.T) {
+	assert := require.New(t)
+	out := filepath.Join(t.TempDir(), `events.log`)
+	t.Setenv(`PROCWATCH_TEST_LISTENER_OUT`, out)
+
+	manager, err := newManager(`eventlistener`)
+	assert.NoError(err)
+	assert.Len(manager.EventListenerPools(), 1)
+
+	listener, ok := manager.Program(`recorder`)
+	assert.True(ok)
+	assert.True(listener.EventListener)
+
+	go manager.Run()
+	time.Sleep(3 * time.Second)
+	stopAndVerifyManager(manager, assert)
+
+	data, err := os.ReadFile(out)
+	assert.NoError(err)
+	assert.Contains(string(data), `eventname:PROCESS_STATE_RUNNING`)
+	assert.Contains(string(data), `processname:one-success groupname:one-success from_state:STARTING pid:`)
+	assert.Contains(string(data), `eventname:PROCESS_STATE_EXITED`)
+	assert.NotContains(string(data), `eventname:PROCESS_STATE_STARTING`)
+}
+
+func TestEventListenerStuck(t *testing.T) {
+	assert := require.New(t)
+	defer func(timeout time.Duration) {
+		EventListenerWriteTimeout = timeout
+	}(EventListenerWriteTimeout)
+
+	EventListenerWriteTimeout = 100 * time.Millisecond
+
+	// the read end is never read from, so writes block once the pipe is full
+	r, w, err := os.Pipe()
+	assert.NoError(err)
+	defer r.Close()
+
+	var pool = newEventListenerPool(`stuck`, []string{`EVENT`}, 0, nil)
+	var listener = &eventListener{
+		program: &Program{Name: `stuck`},
+		pool:    pool,
+	}
+
+	pool.members = append(pool.members, listener)
+	listener.attach(w)
+	listener.handleOutput(`READY`)
+
+	var event = NewEvent([]string{`PROCESS_LOG`}, `stuck`, ProgramSource, nil)
+	event.Data = string(make([]byte, 1<<20))
+
+	var pushed = make(chan bool)
+
+	go func() {
+		pool.Push(event)
+		pushed <- true
+	}()
+
+	select {
+	case <-pushed:
+	case <-time.After(time.Second):
+		assert.Fail(`pushing an event blocked on a listener that isn't reading`)
+	}
+
+	// the listener is given up on and the event kept for another one
+	assert.Eventually(func() bool {
+		listener.lock.Lock()
+		defer listener.lock.Unlock()
+
+		return listener.state == ListenerUnknown
+	}, 5*time.Second, 10*time.Millisecond)
+
+	assert.Equal(1, pool.Buffered())
+}
+
+func TestProgramUser(t *testing.T) {
+	assert := require.New(t)
+	logdir := t.TempDir()
+	t.Setenv(`PROCWATCH_TEST_LOGDIR`, logdir)
+
+	manager, err := newManager(`user`)
+	assert.NoError(err)
+
+	program, ok := manager.Program(`unprivileged`)
+	assert.True(ok)
+
+	nobody, err := user.Lookup(`nobody`)
+	assert.NoError(err)
+
+	if fmt.Sprintf("%d", os.Geteuid()) == nobody.Uid {
+		t.Skip(`already running as nobody`)
+	}
+
+	if os.Geteuid() != 0 {
+		_, err := program.credential()
+		assert.ErrorIs(err, ErrCannotSwitchUser)
+
+		go manager.Run()
+		time.Sleep(time.Second)
+		assert.Equal(ProgramFatal, program.GetState())
+		stopAndVerifyManager(manager, assert)
+		return
+	}
+
+	credential, err := program.credential()
+	assert.NoError(err)
+	assert.Equal(nobody.Uid, fmt.Sprintf("%d", credential.Uid))
+	assert.Equal(nobody.Gid, fmt.Sprintf("%d", credential.Gid))
+	env, err := program.getEnvironment()
+	assert.NoError(err)
+	assert.Contains(env, `HOME=`+nobody.HomeDir)
+
+	go manager.Run()
+	time.Sleep(time.Second)
+	stopAndVerifyManager(manager, assert)
+
+	logfile := program.LogfilePath(true)
+	assert.Equal(filepath.Join(logdir, `unprivileged_out.log`), logfile)
+
+	data, err := os.ReadFile(logfile)
+	assert.NoError(err)
+	assert.Contains(string(data), ` `+nobody.Uid+"\n")
+	assert.Contains(string(data), ` nobody`+"\n")
+	assert.Contains(string(data), ` `+nobody.HomeDir+"\n")
+
+	stat, err := os.Stat(logfile)
+	assert.NoError(err)
+	assert.Equal(nobody.Uid, fmt.Sprintf("%d", stat.Sys().(*syscall.Stat_t).Uid))
+}
+
+func TestProgramSpawnOptions(t *testing.T) {
+	assert := require.New(t)
+	logdir := t.TempDir()
+	t.Setenv(`PROCWATCH_TEST_LOGDIR`, logdir)
+
+	manager, err := newManager(`spawn`)
+	assert.NoError(err)
+
+	masked, ok := manager.Program(`masked`)
+	assert.True(ok)
+	assert.Equal(027, masked.UMask)
+
+	unpadded, ok := manager.Program(`unpadded`)
+	assert.True(ok)
+	assert.Equal(022, unpadded.UMask)
+
+	unmasked, ok := manager.Program(`unmasked`)
+	assert.True(ok)
+
+	nowhere, ok := manager.Program(`nowhere`)
+	assert.True(ok)
+
+	missing, ok := manager.Program(`missing`)
+	assert.True(ok)
+
+	go manager.Run()
+	time.Sleep(time.Second)
+
+	// bad directories are not retried, even with autorestart
+	assert.Equal(ProgramFatal, nowhere.GetState())
+	assert.Contains(nowhere.SpawnError, `directory does not exist: /nonexistent/directory`)
+	assert.Equal(ProgramFatal, missing.GetState())
+	assert.Contains(missing.SpawnError, `command not found: /tmp/does-not-exist`)
+	assert.Empty(masked.SpawnError)
+
+	stopAndVerifyManager(manager, assert)
+
+	data, err := os.ReadFile(masked.LogfilePath(true))
+	assert.NoError(err)
+	assert.Contains(string(data), "0027\n")
+
+	data, err = os.ReadFile(unmasked.LogfilePath(true))
+	assert.NoError(err)
+	assert.Contains(string(data), "0000\n")
+
+	_, err = LoadProgramsFromConfig([]byte("[program:bad]\ncommand = true\numask = 9\n"), newManagerWithDefaults())
+	assert.ErrorContains(err, `umask`)
+}
+
+func TestProgramLogEvents(t *testing.T) {
+	assert := require.New(t)
+	t.Setenv(`PROCWATCH_TEST_LOGDIR`, t.TempDir())
+
+	manager, err := newManager(`logevents`)
+	assert.NoError(err)
+
+	var logged = make(chan *Event, 10)
+
+	manager.AddEventHandler(func(event *Event) {
+		if event.HasName(`PROCESS_LOG`) {
+			logged <- event
+		}
+	})
+
+	go manager.Run()
+	time.Sleep(time.Second)
+
+	var payloads = make(map[string]string)
+
+	for len(logged) > 0 {
+		var event = <-logged
+		payloads[event.Label+` `+event.Name()] = event.Payload()
+	}
+
+	chatty, ok := manager.Program(`chatty`)
+	assert.True(ok)
+
+	redirected, ok := manager.Program(`redirected`)
+	assert.True(ok)
+
+	assert.Equal(map[string]string{
+		`chatty PROCESS_LOG_STDOUT`: fmt.Sprintf("processname:chatty groupname:chatty pid:%d channel:stdout\nto stdout", chatty.PID()),
+		`chatty PROCESS_LOG_STDERR`: fmt.Sprintf("processname:chatty groupname:chatty pid:%d channel:stderr\nto stderr", chatty.PID()),
+		`redirected PROCESS_LOG_STDOUT`: fmt.Sprintf(
+			"processname:redirected groupname:redirected pid:%d channel:stdout\nto stderr",
+			redirected.PID(),
+		),
+	}, payloads)
+
+	stopAndVerifyManager(manager, assert)
+}
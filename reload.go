@@ -0,0 +1,377 @@
+package procwatch
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/ghetzel/go-stockutil/log"
+)
+
+var ErrNoSuchGroup = errors.New(`no such group`)
+var ErrGroupAlreadyAdded = errors.New(`group already added`)
+var ErrGroupStillRunning = errors.New(`group still running`)
+
+// these fields describe the running state of a program rather than its configuration
+var programRuntimeFields = []string{
+	`state`,
+	`pid`,
+	`index`,
+	`last_exit_status`,
+	`spawnerr`,
+	`last_started_at`,
+	`last_exited_at`,
+	`last_triggered_at`,
+	`next_scheduled_at`,
+}
+
+// The differences between the running configuration and the one on disk, by group name.
+type ConfigDiff struct {
+	Added   []string `json:"added"`
+	Changed []string `json:"changed"`
+	Removed []string `json:"removed"`
+}
+
+// Loads the configuration from disk into a new, unstarted manager and compares its groups
+// to the running ones.  Nothing is started or stopped; the loaded configuration is kept so
+// that new or changed groups can subsequently be brought in with AddProcessGroup.
+func (manager *Manager) Reread() (*ConfigDiff, error) {
+	manager.updateLock.Lock()
+	defer manager.updateLock.Unlock()
+
+	return manager.rereadConfig()
+}
+
+// Reread, for callers that already hold updateLock.
+func (manager *Manager) rereadConfig() (*ConfigDiff, error) {
+	var candidate = newManagerWithDefaults()
+	candidate.ConfigFile = manager.ConfigFile
+
+	if err := candidate.loadConfig(); err != nil {
+		return nil, err
+	}
+
+	var diff = &ConfigDiff{
+		Added:   make([]string, 0),
+		Changed: make([]string, 0),
+		Removed: make([]string, 0),
+	}
+
+	for _, group := range candidate.Groups() {
+		if current, ok := manager.Group(group.Name); ok {
+			if !sameGroupConfig(current, group) {
+				diff.Changed = append(diff.Changed, group.Name)
+			}
+		} else {
+			diff.Added = append(diff.Added, group.Name)
+		}
+	}
+
+	for _, group := range manager.Groups() {
+		if _, ok := candidate.Group(group.Name); !ok {
+			diff.Removed = append(diff.Removed, group.Name)
+		}
+	}
+
+	manager.reread = candidate
+
+	return diff, nil
+}
+
+// Adds a group from the most recently reread configuration to the running manager.  Its
+// autostart programs are then started in the background, in priority order.
+func (manager *Manager) AddProcessGroup(name string) error {
+	manager.updateLock.Lock()
+	defer manager.updateLock.Unlock()
+
+	if err := manager.addProcessGroup(name); err != nil {
+		return err
+	}
+
+	if group, ok := manager.Group(name); ok {
+		go manager.startInPriorityOrder(group.Members())
+	}
+
+	return nil
+}
+
+func (manager *Manager) addProcessGroup(name string) error {
+	if _, ok := manager.Group(name); ok {
+		return fmt.Errorf("%v: %w", name, ErrGroupAlreadyAdded)
+	} else if manager.reread == nil {
+		return fmt.Errorf("%v: %w", name, ErrNoSuchGroup)
+	}
+
+	if group, ok := manager.reread.Group(name); ok {
+		var members = group.Members()
+
+		manager.reloadLock.Lock()
+		defer manager.reloadLock.Unlock()
+
+		var programs = append([]*Program{}, manager.programs...)
+
+		for _, program := range members {
+			program.manager = manager
+			program.LoadIndex = len(programs)
+			programs = append(programs, program)
+
+			if listener := program.listener; listener != nil {
+				var known bool
+
+				for _, pool := range manager.listenerPools {
+					known = known || (pool == listener.pool)
+				}
+
+				if !known {
+					listener.pool.manager = manager
+					manager.listenerPools = append(manager.listenerPools, listener.pool)
+				}
+			}
+		}
+
+		group.manager = manager
+		manager.groups = append(manager.groups, group)
+		manager.programs = programs
+
+		return nil
+	} else {
+		return fmt.Errorf("%v: %w", name, ErrNoSuchGroup)
+	}
+}
+
+// Removes a group whose programs are all stopped from the running manager.
+func (manager *Manager) RemoveProcessGroup(name string) error {
+	manager.updateLock.Lock()
+	defer manager.updateLock.Unlock()
+
+	return manager.removeProcessGroup(name)
+}
+
+func (manager *Manager) removeProcessGroup(name string) error {
+	if group, ok := manager.Group(name); ok {
+		var members = group.Members()
+
+		for _, program := range members {
+			if !program.InTerminalState() {
+				return fmt.Errorf("%v: %w", name, ErrGroupStillRunning)
+			}
+		}
+
+		manager.reloadLock.Lock()
+		defer manager.reloadLock.Unlock()
+
+		var programs = make([]*Program, 0)
+		var groups = make([]*Group, 0)
+		var pools = make([]*EventListenerPool, 0)
+
+		for _, program := range manager.programs {
+			if program.Group != name {
+				program.LoadIndex = len(programs)
+				programs = append(programs, program)
+			}
+		}
+
+		for _, g := range manager.groups {
+			if g != group {
+				groups = append(groups, g)
+			}
+		}
+
+		// pools that were left without any members are dropped
+		for _, pool := range manager.listenerPools {
+			pool.lock.Lock()
+			var remaining = make([]*eventListener, 0)
+
+			for _, member := range pool.members {
+				if member.program.Group != name {
+					remaining = append(remaining, member)
+				}
+			}
+
+			pool.members = remaining
+			pool.lock.Unlock()
+
+			if len(remaining) > 0 {
+				pools = append(pools, pool)
+			}
+		}
+
+		manager.programs = programs
+		manager.groups = groups
+		manager.listenerPools = pools
+
+		return nil
+	} else {
+		return fmt.Errorf("%v: %w", name, ErrNoSuchGroup)
+	}
+}
+
+// Rereads the configuration and applies it program by program: programs that were removed are
+// stopped and dropped, programs whose configuration changed are stopped and replaced, and new
+// programs are added.  Programs whose configuration did not change are left running.  New and
+// replaced autostart programs are started in priority order before this returns.
+func (manager *Manager) Update() (*ConfigDiff, error) {
+	manager.updateLock.Lock()
+	defer manager.updateLock.Unlock()
+
+	var diff, err = manager.rereadConfig()
+
+	if err != nil {
+		return nil, err
+	}
+
+	var candidate = manager.reread
+	var unchanged = make(map[string]*Program)
+	var stale = make([]*Program, 0)
+
+	for _, program := range manager.Programs() {
+		if next, ok := candidate.Program(program.FullName()); ok && next.configSignature() == program.configSignature() {
+			unchanged[program.FullName()] = program
+		} else {
+			stale = append(stale, program)
+		}
+	}
+
+	for _, tier := range priorityTiers(stale, true) {
+		for _, program := range tier {
+			log.Infof("Stopping program %s", program.Name)
+
+			if program.InState(ProgramBackoff) {
+				// nothing is running, but it mustn't be retried while being removed
+				program.transitionTo(ProgramStopped)
+			} else {
+				program.Stop()
+			}
+		}
+	}
+
+	var programs = make([]*Program, 0)
+	var added = make([]*Program, 0)
+
+	for _, program := range candidate.Programs() {
+		if current, ok := unchanged[program.FullName()]; ok {
+			program = current
+		} else {
+			log.Infof("Adding program %s", program.Name)
+			program.manager = manager
+			added = append(added, program)
+		}
+
+		program.LoadIndex = len(programs)
+		programs = append(programs, program)
+	}
+
+	var groups = candidate.Groups()
+
+	for _, group := range groups {
+		group.manager = manager
+	}
+
+	manager.reloadLock.Lock()
+	manager.programs = programs
+	manager.groups = groups
+	manager.listenerPools = manager.rebuildListenerPools(programs)
+	manager.reloadLock.Unlock()
+
+	// the reread configuration now belongs to the running manager
+	manager.reread = nil
+
+	manager.startInPriorityOrder(added)
+
+	return diff, nil
+}
+
+// Returns the event listener pools for the given programs.  A pool that still has a listener
+// which was left running is kept, along with the events buffered in it, and listeners that
+// were added or replaced join it; otherwise the pool from the new configuration is used.
+func (manager *Manager) rebuildListenerPools(programs []*Program) []*EventListenerPool {
+	var pools = make([]*EventListenerPool, 0)
+	var members = make(map[*EventListenerPool][]*eventListener)
+
+	var poolNamed = func(name string) *EventListenerPool {
+		for _, pool := range pools {
+			if pool.Name == name {
+				return pool
+			}
+		}
+
+		return nil
+	}
+
+	for _, program := range programs {
+		if listener := program.listener; listener != nil && listener.pool.manager == manager && poolNamed(listener.pool.Name) == nil {
+			pools = append(pools, listener.pool)
+		}
+	}
+
+	for _, program := range programs {
+		if listener := program.listener; listener != nil {
+			if pool := poolNamed(listener.pool.Name); pool != nil {
+				listener.pool = pool
+			} else {
+				listener.pool.manager = manager
+				pools = append(pools, listener.pool)
+			}
+
+			members[listener.pool] = append(members[listener.pool], listener)
+		}
+	}
+
+	for _, pool := range pools {
+		pool.lock.Lock()
+		pool.members = members[pool]
+		pool.lock.Unlock()
+	}
+
+	return pools
+}
+
+func sameGroupConfig(a *Group, b *Group) bool {
+	if a.Priority != b.Priority {
+		return false
+	}
+
+	var sigA = programSignatures(a.Members())
+	var sigB = programSignatures(b.Members())
+
+	if len(sigA) != len(sigB) {
+		return false
+	}
+
+	for i := range sigA {
+		if sigA[i] != sigB[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+func programSignatures(programs []*Program) []string {
+	var signatures = make([]string, len(programs))
+
+	for i, program := range programs {
+		signatures[i] = program.configSignature()
+	}
+
+	sort.Strings(signatures)
+	return signatures
+}
+
+// Returns a string that differs between programs if and only if their configuration does.
+func (program *Program) configSignature() string {
+	var config = make(map[string]any)
+
+	if data, err := json.Marshal(program); err == nil {
+		json.Unmarshal(data, &config)
+	}
+
+	for _, field := range programRuntimeFields {
+		delete(config, field)
+	}
+
+	// map keys are marshaled in sorted order, so this is stable
+	data, _ := json.Marshal(config)
+	return string(data)
+}
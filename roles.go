@@ -0,0 +1,172 @@
+package procwatch
+
+import (
+	"fmt"
+	"net/http"
+	"path/filepath"
+)
+
+// What a role is allowed to do.  Each permission includes the ones before it.
+type Permission int
+
+const (
+	PermissionNone Permission = iota
+	PermissionRead
+	PermissionOperate
+	PermissionAdmin
+)
+
+// The built-in roles, which can be assigned to tokens directly or used as the "permission"
+// of a [role:NAME] section.
+const (
+	RoleReadOnly = `read-only`
+	RoleOperator = `operator`
+	RoleAdmin    = `admin`
+)
+
+var rolePermissions = map[string]Permission{
+	RoleReadOnly: PermissionRead,
+	RoleOperator: PermissionOperate,
+	RoleAdmin:    PermissionAdmin,
+}
+
+// A Role grants a permission, optionally limited to the programs matching any of a list of
+// globs.  Globs are matched against a program's name, its group name, and its full
+// "group:name" name, so "web:*" and "web" both match every program in the "web" group.
+type Role struct {
+	Name       string   `json:"name"               ini:"-"`
+	Permission string   `json:"permission"         ini:"permission"`
+	Programs   []string `json:"programs,omitempty" delim:"," ini:"programs"`
+}
+
+func (role *Role) level() Permission {
+	return rolePermissions[role.Permission]
+}
+
+// Returns whether the role can act on every program, rather than a subset of them.
+func (role *Role) Unrestricted() bool {
+	return len(role.Programs) == 0
+}
+
+// Returns whether the role grants the given permission at all.
+func (role *Role) Can(permission Permission) bool {
+	return role.level() >= permission
+}
+
+// Returns whether the role grants the given permission over the given program.
+func (role *Role) Allows(permission Permission, program *Program) bool {
+	if !role.Can(permission) {
+		return false
+	} else if role.Unrestricted() {
+		return true
+	}
+
+	for _, pattern := range role.Programs {
+		for _, name := range []string{program.Name, program.Group, program.FullName()} {
+			if ok, _ := filepath.Match(pattern, name); ok {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func (role *Role) validate() error {
+	if _, ok := rolePermissions[role.Permission]; !ok {
+		return fmt.Errorf("role %s: invalid permission %q", role.Name, role.Permission)
+	}
+
+	for _, pattern := range role.Programs {
+		if _, err := filepath.Match(pattern, ``); err != nil {
+			return fmt.Errorf("role %s: invalid pattern %q", role.Name, pattern)
+		}
+	}
+
+	return nil
+}
+
+// Returns the role with the given name, which is either one defined in a [role:NAME]
+// section or one of the built-in roles.
+func (server *Server) Role(name string) (*Role, bool) {
+	for _, role := range server.Roles {
+		if role.Name == name {
+			return role, true
+		}
+	}
+
+	if _, ok := rolePermissions[name]; ok {
+		return &Role{
+			Name:       name,
+			Permission: name,
+		}, true
+	}
+
+	return nil, false
+}
+
+// Returns the role that the given request was made with.  When authentication is disabled,
+// every request is made with the admin role.
+func (server *Server) requestRole(req *http.Request) *Role {
+	if principal, ok := RequestPrincipal(req); ok {
+		if role, ok := server.Role(principal.Role); ok {
+			return role
+		}
+
+		return &Role{Name: principal.Role}
+	} else if !server.AuthEnabled() {
+		role, _ := server.Role(RoleAdmin)
+		return role
+	}
+
+	return &Role{}
+}
+
+// Checks that the request's role grants the given permission over all of the given programs
+// (or at all, if none are given), responding with a 403 error if it doesn't.
+func (server *Server) authorize(w http.ResponseWriter, req *http.Request, permission Permission, programs ...*Program) bool {
+	var role = server.requestRole(req)
+	var reason string
+
+	if !role.Can(permission) {
+		reason = `Forbidden`
+	} else {
+		for _, program := range programs {
+			if !role.Allows(permission, program) {
+				reason = fmt.Sprintf("Forbidden: no access to program '%s'", program.FullName())
+				break
+			}
+		}
+	}
+
+	if reason != `` {
+		server.auditDenied(req, reason)
+		http.Error(w, reason, http.StatusForbidden)
+		return false
+	}
+
+	return true
+}
+
+// Wraps a handler so that it is only called if the request's role grants the given permission.
+func (server *Server) require(permission Permission, handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		if server.authorize(w, req, permission) {
+			handler(w, req)
+		}
+	}
+}
+
+// Returns the programs that the request's role is allowed to see.
+func (server *Server) visiblePrograms(req *http.Request, programs []*Program) []*Program {
+	var role = server.requestRole(req)
+	var visible = make([]*Program, 0)
+
+	for _, program := range programs {
+		if role.Allows(PermissionRead, program) {
+			visible = append(visible, program)
+		}
+	}
+
+	return visible
+}
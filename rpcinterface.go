@@ -0,0 +1,816 @@
+package procwatch
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/ghetzel/go-stockutil/log"
+	"github.com/ghetzel/go-stockutil/typeutil"
+)
+
+// The version of the Supervisor XML-RPC API that procwatch implements.
+const SupervisorAPIVersion = `3.0`
+
+// Supervisor XML-RPC fault codes
+const (
+	FaultUnknownMethod        = 1
+	FaultIncorrectParameters  = 2
+	FaultBadArguments         = 3
+	FaultSignatureUnsupported = 4
+	FaultShutdownState        = 6
+	FaultBadName              = 10
+	FaultBadSignal            = 11
+	FaultNoFile               = 20
+	FaultNotExecutable        = 21
+	FaultFailed               = 30
+	FaultAbnormalTermination  = 40
+	FaultSpawnError           = 50
+	FaultAlreadyStarted       = 60
+	FaultNotRunning           = 70
+	FaultSuccess              = 80
+	FaultAlreadyAdded         = 90
+	FaultStillRunning         = 91
+	FaultCantReread           = 92
+)
+
+var faultNames = map[int]string{
+	FaultUnknownMethod:        `UNKNOWN_METHOD`,
+	FaultIncorrectParameters:  `INCORRECT_PARAMETERS`,
+	FaultBadArguments:         `BAD_ARGUMENTS`,
+	FaultSignatureUnsupported: `SIGNATURE_UNSUPPORTED`,
+	FaultShutdownState:        `SHUTDOWN_STATE`,
+	FaultBadName:              `BAD_NAME`,
+	FaultBadSignal:            `BAD_SIGNAL`,
+	FaultNoFile:               `NO_FILE`,
+	FaultNotExecutable:        `NOT_EXECUTABLE`,
+	FaultFailed:               `FAILED`,
+	FaultAbnormalTermination:  `ABNORMAL_TERMINATION`,
+	FaultSpawnError:           `SPAWN_ERROR`,
+	FaultAlreadyStarted:       `ALREADY_STARTED`,
+	FaultNotRunning:           `NOT_RUNNING`,
+	FaultSuccess:              `SUCCESS`,
+	FaultAlreadyAdded:         `ALREADY_ADDED`,
+	FaultStillRunning:         `STILL_RUNNING`,
+	FaultCantReread:           `CANT_REREAD`,
+}
+
+func fault(code int, detail ...any) *XmlRpcFault {
+	var msg = faultNames[code]
+
+	if len(detail) > 0 {
+		msg += `: ` + fmt.Sprint(detail...)
+	}
+
+	return &XmlRpcFault{
+		Code:   code,
+		String: msg,
+	}
+}
+
+// Returns the numeric state code Supervisor uses for the given program state.
+func (state ProgramState) Code() int {
+	switch state {
+	case ProgramStopped:
+		return 0
+	case ProgramStarting:
+		return 10
+	case ProgramRunning:
+		return 20
+	case ProgramBackoff:
+		return 30
+	case ProgramStopping:
+		return 40
+	case ProgramExited:
+		return 100
+	case ProgramFatal:
+		return 200
+	default:
+		return 1000
+	}
+}
+
+type rpcMethod struct {
+	help       string
+	permission Permission
+	fn         func(args []any) (any, error)
+}
+
+// Implements the supervisor.* and system.* XML-RPC namespaces on top of a Manager.  If Role is
+// set, calls are limited to the methods and programs that it allows.  If Audit is set, it is
+// called for every action that changes the state of a program or the manager.
+type RPCInterface struct {
+	Role    *Role
+	Audit   func(entry *AuditEntry)
+	manager *Manager
+	methods map[string]rpcMethod
+}
+
+func NewRPCInterface(manager *Manager) *RPCInterface {
+	var rpc = &RPCInterface{
+		manager: manager,
+	}
+
+	rpc.methods = map[string]rpcMethod{
+		`supervisor.getAPIVersion`:        {`Return the version of the RPC API used by procwatch`, PermissionRead, rpc.getAPIVersion},
+		`supervisor.getVersion`:           {`Return the version of the RPC API used by procwatch`, PermissionRead, rpc.getAPIVersion},
+		`supervisor.getSupervisorVersion`: {`Return the version of procwatch`, PermissionRead, rpc.getSupervisorVersion},
+		`supervisor.getIdentification`:    {`Return the identifying string of procwatch`, PermissionRead, rpc.getIdentification},
+		`supervisor.getState`:             {`Return the current state of procwatch`, PermissionRead, rpc.getState},
+		`supervisor.getPID`:               {`Return the PID of procwatch`, PermissionRead, rpc.getPID},
+		`supervisor.shutdown`:             {`Shut down procwatch, stopping all processes`, PermissionAdmin, rpc.shutdown},
+		`supervisor.readLog`:              {`Read length bytes from the main log starting at offset`, PermissionRead, rpc.readLog},
+		`supervisor.reloadConfig`:         {`Reload the configuration, returning the names of added, changed, and removed groups`, PermissionAdmin, rpc.reloadConfig},
+		`supervisor.addProcessGroup`:      {`Update the config for a running process from the config file`, PermissionAdmin, rpc.addProcessGroup},
+		`supervisor.removeProcessGroup`:   {`Remove a stopped process group from the active configuration`, PermissionAdmin, rpc.removeProcessGroup},
+		`supervisor.startProcess`:         {`Start a process`, PermissionOperate, rpc.startProcess},
+		`supervisor.startProcessGroup`:    {`Start all processes in the group named 'name'`, PermissionOperate, rpc.startProcessGroup},
+		`supervisor.startAllProcesses`:    {`Start all processes listed in the configuration file`, PermissionOperate, rpc.startAllProcesses},
+		`supervisor.stopProcess`:          {`Stop a process named by name`, PermissionOperate, rpc.stopProcess},
+		`supervisor.stopProcessGroup`:     {`Stop all processes in the process group named 'name'`, PermissionOperate, rpc.stopProcessGroup},
+		`supervisor.stopAllProcesses`:     {`Stop all processes in the process list`, PermissionOperate, rpc.stopAllProcesses},
+		`supervisor.signalProcess`:        {`Send an arbitrary UNIX signal to the process named by name`, PermissionOperate, rpc.signalProcess},
+		`supervisor.signalProcessGroup`:   {`Send a signal to all processes in the group named 'name'`, PermissionOperate, rpc.signalProcessGroup},
+		`supervisor.signalAllProcesses`:   {`Send a signal to all processes in the process list`, PermissionOperate, rpc.signalAllProcesses},
+		`supervisor.sendProcessStdin`:     {`Send a string of chars to the stdin of the process name`, PermissionOperate, rpc.sendProcessStdin},
+		`supervisor.getProcessInfo`:       {`Get info about a process named name`, PermissionRead, rpc.getProcessInfo},
+		`supervisor.getAllProcessInfo`:    {`Get info about all processes`, PermissionRead, rpc.getAllProcessInfo},
+		`supervisor.readProcessStdoutLog`: {`Read length bytes from name's stdout log starting at offset`, PermissionRead, rpc.readProcessLog(true)},
+		`supervisor.readProcessStderrLog`: {`Read length bytes from name's stderr log starting at offset`, PermissionRead, rpc.readProcessLog(false)},
+		`supervisor.tailProcessStdoutLog`: {`Provides a more efficient way to tail the stdout log than readProcessStdoutLog()`, PermissionRead, rpc.tailProcessLog(true)},
+		`supervisor.tailProcessStderrLog`: {`Provides a more efficient way to tail the stderr log than readProcessStderrLog()`, PermissionRead, rpc.tailProcessLog(false)},
+		`system.listMethods`:              {`Return an array listing the available method names`, PermissionRead, rpc.listMethods},
+		`system.methodHelp`:               {`Return a string showing the method's documentation`, PermissionRead, rpc.methodHelp},
+		`system.methodSignature`:          {`Return an array describing the method signature`, PermissionRead, rpc.methodSignature},
+		`system.multicall`:                {`Process an array of calls, and return an array of results`, PermissionRead, rpc.multicall},
+	}
+
+	return rpc
+}
+
+func (rpc *RPCInterface) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	var result any
+
+	if method, args, err := DecodeXmlRpcCall(req.Body); err == nil {
+		log.Debugf("xmlrpc: %s%v", method, args)
+
+		if value, err := rpc.Call(method, args); err == nil {
+			result = value
+		} else {
+			result = rpc.toFault(err)
+		}
+	} else {
+		result = fault(FaultIncorrectParameters, err)
+	}
+
+	w.Header().Set(`Content-Type`, `text/xml`)
+
+	if err := EncodeXmlRpcResponse(w, result); err != nil {
+		log.Errorf("xmlrpc: %v", err)
+	}
+}
+
+// Calls the named method with the given arguments.
+func (rpc *RPCInterface) Call(method string, args []any) (any, error) {
+	if m, ok := rpc.methods[method]; ok {
+		if err := rpc.authorize(m.permission); err != nil {
+			return nil, err
+		}
+
+		return m.fn(args)
+	} else {
+		return nil, fault(FaultUnknownMethod, method)
+	}
+}
+
+func (rpc *RPCInterface) toFault(err error) *XmlRpcFault {
+	var f *XmlRpcFault
+
+	switch {
+	case errors.As(err, &f):
+		return f
+	case errors.Is(err, ErrNoSuchGroup):
+		return fault(FaultBadName, err)
+	case errors.Is(err, ErrGroupAlreadyAdded):
+		return fault(FaultAlreadyAdded, err)
+	case errors.Is(err, ErrGroupStillRunning):
+		return fault(FaultStillRunning, err)
+	case errors.Is(err, ErrNotRunning), errors.Is(err, ErrStdinClosed):
+		return fault(FaultNotRunning, err)
+	case errors.Is(err, ErrNoLogFile), errors.Is(err, ErrStdinNotEnabled):
+		return fault(FaultNoFile, err)
+	case errors.Is(err, ErrInvalidLogRange):
+		return fault(FaultBadArguments, err)
+	default:
+		return fault(FaultFailed, err)
+	}
+}
+
+func argString(args []any, i int) (string, error) {
+	if i < len(args) {
+		if s, ok := args[i].(string); ok {
+			return s, nil
+		}
+
+		return ``, fault(FaultIncorrectParameters, fmt.Sprintf("argument %d must be a string", i+1))
+	}
+
+	return ``, fault(FaultSignatureUnsupported)
+}
+
+func argInt(args []any, i int) (int, error) {
+	if i < len(args) {
+		if n, ok := args[i].(int); ok {
+			return n, nil
+		}
+
+		return 0, fault(FaultIncorrectParameters, fmt.Sprintf("argument %d must be an integer", i+1))
+	}
+
+	return 0, fault(FaultSignatureUnsupported)
+}
+
+// optional boolean arguments (e.g.: "wait") default to true, as they do in Supervisor
+func argBool(args []any, i int) bool {
+	if i < len(args) {
+		return typeutil.Bool(args[i])
+	}
+
+	return true
+}
+
+// Checks that the role (if any) grants the given permission over all of the given programs.
+func (rpc *RPCInterface) authorize(permission Permission, programs ...*Program) error {
+	if rpc.Role == nil {
+		return nil
+	} else if !rpc.Role.Can(permission) {
+		return fault(FaultFailed, `permission denied`)
+	}
+
+	for _, program := range programs {
+		if !rpc.Role.Allows(permission, program) {
+			return fault(FaultFailed, `permission denied for `, program.FullName())
+		}
+	}
+
+	return nil
+}
+
+// Returns the programs that the role (if any) grants the given permission over, which is what
+// the *All methods act on.
+func (rpc *RPCInterface) programs(permission Permission) []*Program {
+	var programs = make([]*Program, 0)
+
+	for _, program := range rpc.manager.Programs() {
+		if rpc.authorize(permission, program) == nil {
+			programs = append(programs, program)
+		}
+	}
+
+	return programs
+}
+
+func (rpc *RPCInterface) record(entry *AuditEntry) {
+	if rpc.Audit != nil {
+		rpc.Audit(entry)
+	}
+}
+
+func (rpc *RPCInterface) resolve(name string, permission Permission) ([]*Program, error) {
+	if programs, err := rpc.manager.Resolve(name); err == nil {
+		return programs, rpc.authorize(permission, programs...)
+	} else {
+		return nil, fault(FaultBadName, name)
+	}
+}
+
+func (rpc *RPCInterface) resolveOne(name string, permission Permission) (*Program, error) {
+	if program, ok := rpc.manager.Program(name); ok {
+		return program, rpc.authorize(permission, program)
+	} else {
+		return nil, fault(FaultBadName, name)
+	}
+}
+
+func (rpc *RPCInterface) group(name string, permission Permission) (*Group, error) {
+	if group, ok := rpc.manager.Group(name); ok {
+		return group, rpc.authorize(permission, group.Members()...)
+	} else {
+		return nil, fault(FaultBadName, name)
+	}
+}
+
+func (rpc *RPCInterface) getAPIVersion(args []any) (any, error) {
+	return SupervisorAPIVersion, nil
+}
+
+func (rpc *RPCInterface) getSupervisorVersion(args []any) (any, error) {
+	return Version, nil
+}
+
+func (rpc *RPCInterface) getIdentification(args []any) (any, error) {
+	return `procwatch`, nil
+}
+
+func (rpc *RPCInterface) getState(args []any) (any, error) {
+	if rpc.manager.stopping {
+		return map[string]any{
+			`statecode`: -1,
+			`statename`: `SHUTDOWN`,
+		}, nil
+	}
+
+	return map[string]any{
+		`statecode`: 1,
+		`statename`: `RUNNING`,
+	}, nil
+}
+
+func (rpc *RPCInterface) getPID(args []any) (any, error) {
+	return os.Getpid(), nil
+}
+
+func (rpc *RPCInterface) shutdown(args []any) (any, error) {
+	log.Infof("Shutdown requested through XML-RPC, stopping all programs...")
+	rpc.record(&AuditEntry{Action: `shutdown`})
+	go rpc.manager.Stop(false)
+
+	return true, nil
+}
+
+func (rpc *RPCInterface) readLog(args []any) (any, error) {
+	if offset, err := argInt(args, 0); err != nil {
+		return nil, err
+	} else if length, err := argInt(args, 1); err != nil {
+		return nil, err
+	} else if rpc.Role != nil && !rpc.Role.Unrestricted() {
+		// the main log covers every program, not just the ones the role can see
+		return nil, fault(FaultFailed, `permission denied`)
+	} else {
+		return rpc.readLogFile(rpc.manager.LogFile, offset, length)
+	}
+}
+
+func (rpc *RPCInterface) reloadConfig(args []any) (any, error) {
+	if diff, err := rpc.manager.Reread(); err == nil {
+		return []any{
+			[]any{diff.Added, diff.Changed, diff.Removed},
+		}, nil
+	} else {
+		return nil, fault(FaultCantReread, err)
+	}
+}
+
+func (rpc *RPCInterface) addProcessGroup(args []any) (any, error) {
+	if name, err := argString(args, 0); err != nil {
+		return nil, err
+	} else if err := rpc.manager.AddProcessGroup(name); err != nil {
+		rpc.record(&AuditEntry{Action: `add`, Group: name, Error: err.Error()})
+		return nil, err
+	} else {
+		rpc.record(&AuditEntry{Action: `add`, Group: name})
+	}
+
+	return true, nil
+}
+
+func (rpc *RPCInterface) removeProcessGroup(args []any) (any, error) {
+	if name, err := argString(args, 0); err != nil {
+		return nil, err
+	} else if err := rpc.manager.RemoveProcessGroup(name); err != nil {
+		rpc.record(&AuditEntry{Action: `remove`, Group: name, Error: err.Error()})
+		return nil, err
+	} else {
+		rpc.record(&AuditEntry{Action: `remove`, Group: name})
+	}
+
+	return true, nil
+}
+
+func (rpc *RPCInterface) startProcess(args []any) (any, error) {
+	var name, err = argString(args, 0)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if programs, err := rpc.resolve(name, PermissionOperate); err == nil {
+		for _, program := range programs {
+			if err := rpc.start(program, argBool(args, 1)); err != nil {
+				return nil, err
+			}
+		}
+
+		return true, nil
+	} else {
+		return nil, err
+	}
+}
+
+func (rpc *RPCInterface) start(program *Program, wait bool) (err error) {
+	defer func() {
+		rpc.record(programAuditEntry(`start`, program, err))
+	}()
+
+	if program.InState(ProgramStarting, ProgramRunning, ProgramStopping) {
+		return fault(FaultAlreadyStarted, program.FullName())
+	}
+
+	if !wait {
+		go program.Start()
+		return nil
+	}
+
+	program.Start()
+
+	switch program.GetState() {
+	case ProgramRunning:
+		return nil
+	case ProgramExited:
+		return fault(FaultAbnormalTermination, program.FullName())
+	default:
+		return fault(FaultSpawnError, program.FullName())
+	}
+}
+
+func (rpc *RPCInterface) stop(program *Program, wait bool) (err error) {
+	defer func() {
+		rpc.record(programAuditEntry(`stop`, program, err))
+	}()
+
+	if !program.InState(ProgramStarting, ProgramRunning) {
+		return fault(FaultNotRunning, program.FullName())
+	}
+
+	if wait {
+		program.Stop()
+	} else {
+		go program.Stop()
+	}
+
+	return nil
+}
+
+// the result struct for each program acted on by the *Group and *All methods
+func (rpc *RPCInterface) statusOf(program *Program, err error) map[string]any {
+	var status = map[string]any{
+		`name`:        program.Name,
+		`group`:       program.Group,
+		`status`:      FaultSuccess,
+		`description`: `OK`,
+	}
+
+	if err != nil {
+		var f = rpc.toFault(err)
+		status[`status`] = f.Code
+		status[`description`] = f.String
+	}
+
+	return status
+}
+
+func (rpc *RPCInterface) startEach(programs []*Program, wait bool) []any {
+	var results = make([]any, 0)
+
+	for _, program := range programs {
+		if program.InState(ProgramStarting, ProgramRunning, ProgramStopping) {
+			continue
+		}
+
+		results = append(results, rpc.statusOf(program, rpc.start(program, wait)))
+	}
+
+	return results
+}
+
+func (rpc *RPCInterface) stopEach(programs []*Program, wait bool) []any {
+	var results = make([]any, 0)
+
+	for _, program := range programs {
+		if !program.InState(ProgramStarting, ProgramRunning) {
+			continue
+		}
+
+		results = append(results, rpc.statusOf(program, rpc.stop(program, wait)))
+	}
+
+	return results
+}
+
+func (rpc *RPCInterface) startProcessGroup(args []any) (any, error) {
+	if name, err := argString(args, 0); err != nil {
+		return nil, err
+	} else if group, err := rpc.group(name, PermissionOperate); err != nil {
+		return nil, err
+	} else {
+		return rpc.startEach(group.Members(), argBool(args, 1)), nil
+	}
+}
+
+func (rpc *RPCInterface) startAllProcesses(args []any) (any, error) {
+	return rpc.startEach(slices.Concat(priorityTiers(rpc.programs(PermissionOperate), false)...), argBool(args, 0)), nil
+}
+
+func (rpc *RPCInterface) stopProcess(args []any) (any, error) {
+	var name, err = argString(args, 0)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if programs, err := rpc.resolve(name, PermissionOperate); err == nil {
+		for _, program := range programs {
+			if err := rpc.stop(program, argBool(args, 1)); err != nil {
+				return nil, err
+			}
+		}
+
+		return true, nil
+	} else {
+		return nil, err
+	}
+}
+
+func (rpc *RPCInterface) stopProcessGroup(args []any) (any, error) {
+	if name, err := argString(args, 0); err != nil {
+		return nil, err
+	} else if group, err := rpc.group(name, PermissionOperate); err != nil {
+		return nil, err
+	} else {
+		return rpc.stopEach(group.Members(), argBool(args, 1)), nil
+	}
+}
+
+func (rpc *RPCInterface) stopAllProcesses(args []any) (any, error) {
+	return rpc.stopEach(slices.Concat(priorityTiers(rpc.programs(PermissionOperate), true)...), argBool(args, 0)), nil
+}
+
+func (rpc *RPCInterface) signalArg(args []any, i int) (ProgramSignal, error) {
+	if name, err := argString(args, i); err != nil {
+		return ``, err
+	} else if signal, err := ParseProgramSignal(name); err == nil {
+		return signal, nil
+	} else {
+		return ``, fault(FaultBadSignal, name)
+	}
+}
+
+func (rpc *RPCInterface) signalProcess(args []any) (any, error) {
+	var name, err = argString(args, 0)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if signal, err := rpc.signalArg(args, 1); err != nil {
+		return nil, err
+	} else if programs, err := rpc.resolve(name, PermissionOperate); err != nil {
+		return nil, err
+	} else {
+		for _, program := range programs {
+			if err := rpc.signal(program, signal); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return true, nil
+}
+
+func (rpc *RPCInterface) signal(program *Program, signal ProgramSignal) error {
+	var err = program.Signal(signal)
+
+	rpc.record(programAuditEntry(`signal `+string(signal), program, err))
+	return err
+}
+
+func (rpc *RPCInterface) signalEach(programs []*Program, signal ProgramSignal) []any {
+	var results = make([]any, 0)
+
+	for _, program := range programs {
+		if !program.InState(ProgramStarting, ProgramRunning, ProgramStopping) {
+			continue
+		}
+
+		results = append(results, rpc.statusOf(program, rpc.signal(program, signal)))
+	}
+
+	return results
+}
+
+func (rpc *RPCInterface) signalProcessGroup(args []any) (any, error) {
+	var name, err = argString(args, 0)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if signal, err := rpc.signalArg(args, 1); err != nil {
+		return nil, err
+	} else if group, err := rpc.group(name, PermissionOperate); err != nil {
+		return nil, err
+	} else {
+		return rpc.signalEach(group.Members(), signal), nil
+	}
+}
+
+func (rpc *RPCInterface) signalAllProcesses(args []any) (any, error) {
+	if signal, err := rpc.signalArg(args, 0); err != nil {
+		return nil, err
+	} else {
+		return rpc.signalEach(rpc.programs(PermissionOperate), signal), nil
+	}
+}
+
+func (rpc *RPCInterface) sendProcessStdin(args []any) (any, error) {
+	if name, err := argString(args, 0); err != nil {
+		return nil, err
+	} else if chars, err := argString(args, 1); err != nil {
+		return nil, err
+	} else if program, err := rpc.resolveOne(name, PermissionOperate); err != nil {
+		return nil, err
+	} else {
+		var err = program.WriteStdin([]byte(chars))
+
+		rpc.record(programAuditEntry(`stdin`, program, err))
+
+		if err != nil {
+			return nil, err
+		}
+
+		return true, nil
+	}
+}
+
+func (rpc *RPCInterface) processInfo(program *Program) map[string]any {
+	var now = time.Now()
+	var start, stop int64
+
+	if !program.LastStartedAt.IsZero() {
+		start = program.LastStartedAt.Unix()
+	}
+
+	if !program.LastExitedAt.IsZero() {
+		stop = program.LastExitedAt.Unix()
+	}
+
+	var pid = program.PID()
+
+	if pid < 0 {
+		pid = 0
+	}
+
+	return map[string]any{
+		`name`:           program.Name,
+		`group`:          program.Group,
+		`description`:    program.String(),
+		`start`:          start,
+		`stop`:           stop,
+		`now`:            now.Unix(),
+		`state`:          program.GetState().Code(),
+		`statename`:      string(program.GetState()),
+		`spawnerr`:       program.SpawnError,
+		`exitstatus`:     program.LastExitStatus,
+		`logfile`:        program.LogfilePath(true),
+		`stdout_logfile`: program.LogfilePath(true),
+		`stderr_logfile`: program.LogfilePath(false),
+		`pid`:            pid,
+	}
+}
+
+func (rpc *RPCInterface) getProcessInfo(args []any) (any, error) {
+	if name, err := argString(args, 0); err != nil {
+		return nil, err
+	} else if program, err := rpc.resolveOne(name, PermissionRead); err != nil {
+		return nil, err
+	} else {
+		return rpc.processInfo(program), nil
+	}
+}
+
+func (rpc *RPCInterface) getAllProcessInfo(args []any) (any, error) {
+	var infos = make([]any, 0)
+
+	for _, program := range rpc.programs(PermissionRead) {
+		infos = append(infos, rpc.processInfo(program))
+	}
+
+	return infos, nil
+}
+
+func (rpc *RPCInterface) programLogfile(args []any, stdout bool) (string, error) {
+	if name, err := argString(args, 0); err != nil {
+		return ``, err
+	} else if program, err := rpc.resolveOne(name, PermissionRead); err != nil {
+		return ``, err
+	} else if logfile, err := program.LogfileName(stdout); err == nil {
+		return logfile, nil
+	} else {
+		return ``, fault(FaultNoFile, name)
+	}
+}
+
+func (rpc *RPCInterface) readLogFile(filename string, offset int, length int) (any, error) {
+	if chunk, err := ReadLogFile(filename, int64(offset), int64(length)); err == nil {
+		return chunk.Data, nil
+	} else if errors.Is(err, os.ErrNotExist) {
+		return nil, fault(FaultNoFile, filename)
+	} else {
+		return nil, err
+	}
+}
+
+func (rpc *RPCInterface) readProcessLog(stdout bool) func(args []any) (any, error) {
+	return func(args []any) (any, error) {
+		if logfile, err := rpc.programLogfile(args, stdout); err != nil {
+			return nil, err
+		} else if offset, err := argInt(args, 1); err != nil {
+			return nil, err
+		} else if length, err := argInt(args, 2); err != nil {
+			return nil, err
+		} else {
+			return rpc.readLogFile(logfile, offset, length)
+		}
+	}
+}
+
+func (rpc *RPCInterface) tailProcessLog(stdout bool) func(args []any) (any, error) {
+	return func(args []any) (any, error) {
+		if logfile, err := rpc.programLogfile(args, stdout); err != nil {
+			return nil, err
+		} else if offset, err := argInt(args, 1); err != nil {
+			return nil, err
+		} else if length, err := argInt(args, 2); err != nil {
+			return nil, err
+		} else if data, next, overflow, err := TailLogFile(logfile, int64(offset), int64(length)); err == nil {
+			return []any{data, next, overflow}, nil
+		} else {
+			return nil, err
+		}
+	}
+}
+
+func (rpc *RPCInterface) listMethods(args []any) (any, error) {
+	var names = make([]string, 0, len(rpc.methods))
+
+	for name := range rpc.methods {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+	return names, nil
+}
+
+func (rpc *RPCInterface) methodHelp(args []any) (any, error) {
+	if name, err := argString(args, 0); err != nil {
+		return nil, err
+	} else if m, ok := rpc.methods[name]; ok {
+		return m.help, nil
+	} else {
+		return nil, fault(FaultSignatureUnsupported, name)
+	}
+}
+
+func (rpc *RPCInterface) methodSignature(args []any) (any, error) {
+	if name, err := argString(args, 0); err != nil {
+		return nil, err
+	} else if _, ok := rpc.methods[name]; ok {
+		return []any{`undef`}, nil
+	} else {
+		return nil, fault(FaultSignatureUnsupported, name)
+	}
+}
+
+func (rpc *RPCInterface) multicall(args []any) (any, error) {
+	var results = make([]any, 0)
+
+	if len(args) == 0 {
+		return nil, fault(FaultSignatureUnsupported)
+	}
+
+	calls, ok := args[0].([]any)
+
+	if !ok {
+		return nil, fault(FaultIncorrectParameters, `expected an array of calls`)
+	}
+
+	for _, c := range calls {
+		var call, _ = c.(map[string]any)
+		var name, _ = call[`methodName`].(string)
+		var params, _ = call[`params`].([]any)
+
+		if name == `system.multicall` {
+			results = append(results, fault(FaultIncorrectParameters, `recursive multicall`))
+		} else if value, err := rpc.Call(name, params); err == nil {
+			results = append(results, []any{value})
+		} else {
+			results = append(results, rpc.toFault(err))
+		}
+	}
+
+	return results, nil
+}
@@ -0,0 +1,141 @@
+package procwatch
+
+import (
+	"bytes"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestXmlRpcCodec(t *testing.T) {
+	assert := require.New(t)
+
+	method, params, err := DecodeXmlRpcCall(strings.NewReader(`<?xml version="1.0"?>
+	<methodCall>
+		<methodName>supervisor.readProcessStdoutLog</methodName>
+		<params>
+			<param><value><string>foo:bar_01</string></value></param>
+			<param><value><int>-100</int></value></param>
+			<param><value>untyped</value></param>
+			<param><value><array><data><value><boolean>1</boolean></value><value><double>1.5</double></value></data></array></value></param>
+			<param><value><struct><member><name>k</name><value><i4>4</i4></value></member></struct></value></param>
+		</params>
+	</methodCall>`))
+
+	assert.NoError(err)
+	assert.Equal(`supervisor.readProcessStdoutLog`, method)
+	assert.Equal([]any{
+		`foo:bar_01`,
+		-100,
+		`untyped`,
+		[]any{true, 1.5},
+		map[string]any{`k`: 4},
+	}, params)
+
+	var buf bytes.Buffer
+
+	assert.NoError(EncodeXmlRpcResponse(&buf, map[string]any{
+		`b`: []string{`x`, `<y>`},
+		`a`: 1,
+	}))
+
+	assert.Contains(buf.String(), `<params><param><value><struct><member><name>a</name><value><int>1</int></value></member>`)
+	assert.Contains(buf.String(), `<string>&lt;y&gt;</string>`)
+
+	buf.Reset()
+	assert.NoError(EncodeXmlRpcResponse(&buf, []any{uint64(7), uint32(math.MaxUint32), int64(math.MinInt64), nil}))
+	assert.Contains(buf.String(), `<value><int>7</int></value>`)
+	assert.Contains(buf.String(), `<value><i8>4294967295</i8></value>`)
+	assert.Contains(buf.String(), `<value><i8>-9223372036854775808</i8></value>`)
+	assert.Contains(buf.String(), `<value><string></string></value>`)
+
+	buf.Reset()
+	assert.NoError(EncodeXmlRpcResponse(&buf, fault(FaultBadName, `nope`)))
+	assert.Contains(buf.String(), `<fault>`)
+	assert.Contains(buf.String(), `<string>BAD_NAME: nope</string>`)
+}
+
+func TestRPCInterface(t *testing.T) {
+	assert := require.New(t)
+	manager, err := newManager(`one-success`)
+	assert.NoError(err)
+
+	var rpc = NewRPCInterface(manager)
+
+	version, err := rpc.Call(`supervisor.getVersion`, nil)
+	assert.NoError(err)
+	assert.Equal(SupervisorAPIVersion, version)
+
+	infos, err := rpc.Call(`supervisor.getAllProcessInfo`, nil)
+	assert.NoError(err)
+	assert.Len(infos, 1)
+
+	var info = infos.([]any)[0].(map[string]any)
+	assert.Equal(`one-success`, info[`name`])
+	assert.Equal(`one-success`, info[`group`])
+	assert.Equal(0, info[`state`])
+	assert.Equal(`STOPPED`, info[`statename`])
+
+	_, err = rpc.Call(`supervisor.getProcessInfo`, []any{`nonexistent`})
+	assert.Equal(FaultBadName, rpc.toFault(err).Code)
+
+	_, err = rpc.Call(`supervisor.signalProcess`, []any{`one-success`, `BOGUS`})
+	assert.Equal(FaultBadSignal, rpc.toFault(err).Code)
+
+	_, err = rpc.Call(`supervisor.nope`, nil)
+	assert.Equal(FaultUnknownMethod, rpc.toFault(err).Code)
+
+	// over HTTP
+	var w = httptest.NewRecorder()
+
+	rpc.ServeHTTP(w, httptest.NewRequest(http.MethodPost, `/RPC2`, strings.NewReader(
+		`<?xml version="1.0"?><methodCall><methodName>supervisor.getState</methodName><params></params></methodCall>`,
+	)))
+
+	assert.Equal(http.StatusOK, w.Code)
+	assert.Equal(`text/xml`, w.Header().Get(`Content-Type`))
+	assert.Contains(w.Body.String(), `<name>statename</name><value><string>RUNNING</string></value>`)
+}
+
+func TestRPCInterfaceRoles(t *testing.T) {
+	assert := require.New(t)
+	manager, err := newManager(`groups`)
+	assert.NoError(err)
+
+	var rpc = NewRPCInterface(manager)
+
+	rpc.Role = &Role{
+		Name:       `web-oncall`,
+		Permission: RoleOperator,
+		Programs:   []string{`web`},
+	}
+
+	infos, err := rpc.Call(`supervisor.getAllProcessInfo`, nil)
+	assert.NoError(err)
+	assert.Len(infos, 3)
+
+	_, err = rpc.Call(`supervisor.getProcessInfo`, []any{`solo`})
+	assert.Equal(FaultFailed, rpc.toFault(err).Code)
+
+	_, err = rpc.Call(`supervisor.stopProcess`, []any{`solo`})
+	assert.Equal(FaultFailed, rpc.toFault(err).Code)
+
+	_, err = rpc.Call(`supervisor.stopProcessGroup`, []any{`web`})
+	assert.NoError(err)
+
+	_, err = rpc.Call(`supervisor.reloadConfig`, nil)
+	assert.Equal(FaultFailed, rpc.toFault(err).Code)
+
+	// the main log covers programs outside of the role
+	_, err = rpc.Call(`supervisor.readLog`, []any{0, 0})
+	assert.Equal(FaultFailed, rpc.toFault(err).Code)
+
+	rpc.Role.Permission = RoleReadOnly
+
+	_, err = rpc.Call(`supervisor.stopProcessGroup`, []any{`web`})
+	assert.Equal(FaultFailed, rpc.toFault(err).Code)
+}
@@ -0,0 +1,137 @@
+package procwatch
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// How long an ${exec:...} secret reference's command may run before it is killed.
+var SecretExecTimeout = 30 * time.Second
+
+// What is shown in place of a secret's value when logging.
+const SecretMask = `********`
+
+var rxSecretReference = regexp.MustCompile(`\$\{(file|env|exec):([^}]+)\}`)
+
+// Secret references are kept unresolved in the configuration (and so in everything that
+// shows it, like the API) and only resolved when a process is started.
+var secretResolvers = map[string]func(string) (string, error){
+	`file`: func(filename string) (string, error) {
+		if data, err := os.ReadFile(filename); err == nil {
+			return strings.TrimRight(string(data), "\r\n"), nil
+		} else {
+			return ``, err
+		}
+	},
+	`env`: func(name string) (string, error) {
+		if value, ok := os.LookupEnv(name); ok {
+			return value, nil
+		} else {
+			return ``, fmt.Errorf("variable %s is not set", name)
+		}
+	},
+	`exec`: func(command string) (string, error) {
+		var ctx, cancel = context.WithTimeout(context.Background(), SecretExecTimeout)
+		defer cancel()
+
+		if out, err := exec.CommandContext(ctx, `sh`, `-c`, command).Output(); err == nil {
+			return strings.TrimRight(string(out), "\r\n"), nil
+		} else {
+			return ``, err
+		}
+	},
+}
+
+// Returns whether the given value contains any ${file:...}, ${env:...}, or ${exec:...} secret
+// references.
+func HasSecrets(in string) bool {
+	return rxSecretReference.MatchString(in)
+}
+
+// Replaces all ${file:PATH}, ${env:NAME}, and ${exec:COMMAND} references in the given string
+// with the contents of the file, the value of the environment variable, or the output of the
+// command (respectively), less any trailing newlines.
+func ResolveSecrets(in string) (string, error) {
+	var rerr error
+
+	var out = rxSecretReference.ReplaceAllStringFunc(in, func(match string) string {
+		if rerr != nil {
+			return match
+		}
+
+		var value, err = resolveSecret(match)
+
+		if err != nil {
+			rerr = err
+		}
+
+		return value
+	})
+
+	return out, rerr
+}
+
+func resolveSecret(reference string) (string, error) {
+	var parts = rxSecretReference.FindStringSubmatch(reference)
+
+	if value, err := secretResolvers[parts[1]](parts[2]); err == nil {
+		return value, nil
+	} else {
+		// errors are logged, and the logger would take ${...} for a color expression
+		return ``, fmt.Errorf("%s secret %q: %v", parts[1], parts[2], err)
+	}
+}
+
+// The secret references found in a command, in the order they were replaced by placeholders.
+type secretRefs []string
+
+// Replaces the secret references in the given string with placeholders that survive shell
+// word splitting and variable expansion untouched.
+func (refs *secretRefs) protect(in string) string {
+	return rxSecretReference.ReplaceAllStringFunc(in, func(match string) string {
+		*refs = append(*refs, match)
+		return secretPlaceholder(len(*refs) - 1)
+	})
+}
+
+// Resolves each of the references once, in order.
+func (refs secretRefs) resolve() ([]string, error) {
+	var values = make([]string, len(refs))
+
+	for i, ref := range refs {
+		if value, err := resolveSecret(ref); err == nil {
+			values[i] = value
+		} else {
+			return nil, err
+		}
+	}
+
+	return values, nil
+}
+
+// Replaces the placeholders in the given string with the corresponding values.
+func (refs secretRefs) restore(in string, values []string) string {
+	for i := range refs {
+		in = strings.ReplaceAll(in, secretPlaceholder(i), values[i])
+	}
+
+	return in
+}
+
+// Replaces the placeholders in the given string with SecretMask.
+func (refs secretRefs) mask(in string) string {
+	for i := range refs {
+		in = strings.ReplaceAll(in, secretPlaceholder(i), SecretMask)
+	}
+
+	return in
+}
+
+func secretPlaceholder(i int) string {
+	return fmt.Sprintf("__procwatch_secret_%d__", i)
+}
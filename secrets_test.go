@@ -0,0 +1,101 @@
+package procwatch
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolveSecrets(t *testing.T) {
+	assert := require.New(t)
+	t.Setenv(`PROCWATCH_TEST_TOKEN`, `t0ken`)
+
+	var secret = filepath.Join(t.TempDir(), `secret`)
+	assert.NoError(os.WriteFile(secret, []byte("hunter2\n"), 0600))
+
+	out, err := ResolveSecrets(`file=${file:` + secret + `} env=${env:PROCWATCH_TEST_TOKEN} exec=${exec:echo a b}`)
+	assert.NoError(err)
+	assert.Equal(`file=hunter2 env=t0ken exec=a b`, out)
+
+	out, err = ResolveSecrets(`${HOME} $PATH ${other:thing}`)
+	assert.NoError(err)
+	assert.Equal(`${HOME} $PATH ${other:thing}`, out)
+
+	_, err = ResolveSecrets(`${env:PROCWATCH_TEST_UNSET}`)
+	assert.ErrorContains(err, `PROCWATCH_TEST_UNSET is not set`)
+
+	_, err = ResolveSecrets(`${file:/nonexistent/secret}`)
+	assert.Error(err)
+
+	_, err = ResolveSecrets(`${exec:exit 1}`)
+	assert.Error(err)
+
+	var refs secretRefs
+	var protected = refs.protect(`run --token=${env:PROCWATCH_TEST_TOKEN} "${exec:echo a b}"`)
+	assert.False(HasSecrets(protected))
+	assert.Len(refs, 2)
+
+	values, err := refs.resolve()
+	assert.NoError(err)
+	assert.Equal(`run --token=t0ken "a b"`, refs.restore(protected, values))
+	assert.Equal(`run --token=`+SecretMask+` "`+SecretMask+`"`, refs.mask(protected))
+}
+
+func TestProgramSecrets(t *testing.T) {
+	assert := require.New(t)
+	var logdir = t.TempDir()
+
+	t.Setenv(`PROCWATCH_TEST_LOGDIR`, logdir)
+	t.Setenv(`PROCWATCH_TEST_TOKEN`, `t0ken`)
+	assert.NoError(os.WriteFile(filepath.Join(logdir, `secret`), []byte("open sesame\n"), 0600))
+
+	manager, err := newManager(`secrets`)
+	assert.NoError(err)
+
+	var request = serverRequester(assert, manager.Server)
+
+	go manager.Run()
+
+	secretive, _ := manager.Program(`secretive`)
+	unresolvable, _ := manager.Program(`unresolvable`)
+	waitForState(assert, ProgramRunning, secretive)
+	waitForState(assert, ProgramFatal, unresolvable)
+
+	// secrets are resolved when the process starts, and are passed intact
+	assert.Eventually(func() bool {
+		data, _ := os.ReadFile(secretive.LogfilePath(true))
+		return strings.Contains(string(data), ` arg=open sesame password=pass token=t0ken`)
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// ...but only the references are ever shown
+	for _, path := range []string{`/api/programs`, `/api/manager`} {
+		code, body := request(http.MethodGet, path, ``)
+		assert.Equal(http.StatusOK, code)
+
+		for _, secret := range []string{`open sesame`, `pass`, `t0ken`} {
+			assert.NotContains(body, secret)
+		}
+	}
+
+	var programs []*Program
+	_, body := request(http.MethodGet, `/api/programs`, ``)
+	assert.NoError(json.Unmarshal([]byte(body), &programs))
+
+	for _, program := range programs {
+		if program.Name == `secretive` {
+			assert.Equal([]string{`DB_PASSWORD=${exec:echo p4ss | tr 4 a}`, `TOKEN=${env:PROCWATCH_TEST_TOKEN}`}, program.Environment)
+			assert.True(strings.HasSuffix(program.Command.(string), `/secret}`))
+		}
+	}
+
+	// a reference that can't be resolved keeps the program from starting
+	assert.Contains(unresolvable.SpawnError, `PROCWATCH_TEST_UNSET`)
+
+	stopAndVerifyManager(manager, assert)
+}
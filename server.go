@@ -1,17 +1,24 @@
 package procwatch
 
 import (
+	"crypto/tls"
 	"embed"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"io/fs"
+	"net"
 	"net/http"
 	"os"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/ghetzel/diecast"
 	"github.com/ghetzel/go-stockutil/log"
+	"github.com/ghetzel/go-stockutil/typeutil"
 	"github.com/husobee/vestigo"
 	"github.com/urfave/negroni"
 )
@@ -22,11 +29,125 @@ import (
 var embedded embed.FS
 
 var DefaultAddress = `:9001`
+var DefaultSocketMode = `0700`
+
+// The most that can be written to a program's stdin in a single request.
+var MaxStdinBytes int64 = 1 << 20
 
 type Server struct {
-	Address     string `json:"address"                ini:"address"`
-	UiDirectory string `json:"ui_directory,omitempty" ini:"ui_directory"`
-	manager     *Manager
+	Address      string      `json:"address"                 ini:"address"`
+	UiDirectory  string      `json:"ui_directory,omitempty"  ini:"ui_directory"`
+	Chmod        string      `json:"chmod,omitempty"         ini:"chmod"`
+	Chown        string      `json:"chown,omitempty"         ini:"chown"`
+	Username     string      `json:"username,omitempty"      ini:"username"`
+	Password     string      `json:"-"                       ini:"password"`
+	UserRole     string      `json:"role,omitempty"          ini:"role"`
+	PublicStatus bool        `json:"public_status,omitempty" ini:"public_status"`
+	Tokens       []*APIToken `json:"-"                       ini:"-"`
+	Roles        []*Role     `json:"-"                       ini:"-"`
+	TLSCert      string      `json:"tls_cert,omitempty"      ini:"tls_cert"`
+	TLSKey       string      `json:"-"                       ini:"tls_key"`
+	TLSClientCA  string      `json:"tls_client_ca,omitempty" ini:"tls_client_ca"`
+	AuditLog     string      `json:"audit_log,omitempty"     ini:"audit_log"`
+	manager      *Manager
+	tls          tlsState
+	boundLock    sync.Mutex
+	bound        net.Addr
+}
+
+// Returns the path of the Unix domain socket the server listens on if its address is of the
+// form "unix:///path/to/socket".
+func (server *Server) SocketPath() (string, bool) {
+	if path, ok := strings.CutPrefix(server.Address, `unix://`); ok {
+		return path, true
+	}
+
+	return ``, false
+}
+
+// Returns the URL clients can reach the server at: its address if it listens on a Unix domain
+// socket, otherwise an http:// (or https://) URL of the address it is listening on.  Wildcard
+// listen addresses (e.g.: ":9001") are given as localhost.
+func (server *Server) URL() string {
+	if _, ok := server.SocketPath(); ok {
+		return server.Address
+	}
+
+	var address = server.Address
+
+	// the address actually bound is preferred, since the configured port may be 0
+	server.boundLock.Lock()
+	if server.bound != nil {
+		address = server.bound.String()
+	}
+	server.boundLock.Unlock()
+
+	var host, port, err = net.SplitHostPort(address)
+
+	if err != nil {
+		return ``
+	} else if ip := net.ParseIP(host); host == `` || (ip != nil && ip.IsUnspecified()) {
+		host = `localhost`
+	}
+
+	if server.TLSEnabled() {
+		return `https://` + net.JoinHostPort(host, port)
+	} else {
+		return `http://` + net.JoinHostPort(host, port)
+	}
+}
+
+// Opens the listener for the server's address.  Unix sockets are created with the configured
+// permissions and ownership, replacing a stale socket file left behind by a previous run.
+func (server *Server) listen() (net.Listener, error) {
+	var path, ok = server.SocketPath()
+
+	if !ok {
+		return net.Listen(`tcp`, server.Address)
+	}
+
+	// only ever remove a stale socket, never whatever else might have been put in its place
+	if stat, err := os.Lstat(path); err == nil {
+		if stat.Mode()&os.ModeSocket == 0 {
+			return nil, fmt.Errorf("%s already exists and is not a socket", path)
+		} else if conn, err := net.Dial(`unix`, path); err == nil {
+			conn.Close()
+			return nil, fmt.Errorf("another process is already listening on %s", path)
+		} else if err := os.Remove(path); err != nil {
+			return nil, err
+		}
+	}
+
+	var mode, err = strconv.ParseUint(typeutil.OrString(server.Chmod, DefaultSocketMode), 8, 32)
+
+	if err != nil {
+		return nil, fmt.Errorf("invalid chmod %q: %v", server.Chmod, err)
+	}
+
+	listener, err := net.Listen(`unix`, path)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.Chmod(path, os.FileMode(mode)); err != nil {
+		listener.Close()
+		return nil, err
+	}
+
+	if server.Chown != `` {
+		if uid, gid, err := lookupOwner(server.Chown); err == nil {
+			if err := os.Chown(path, uid, gid); err != nil {
+				listener.Close()
+				return nil, err
+			}
+		} else {
+			listener.Close()
+			return nil, fmt.Errorf("invalid chown %q: %v", server.Chown, err)
+		}
+	}
+
+	return listener, nil
 }
 
 func (server *Server) Initialize(manager *Manager) error {
@@ -40,6 +161,33 @@ func (server *Server) Initialize(manager *Manager) error {
 		server.UiDirectory = `embedded`
 	}
 
+	if err := server.validateTLS(); err != nil {
+		return err
+	} else if err := server.ReloadTLS(); err != nil {
+		return err
+	}
+
+	for _, role := range server.Roles {
+		if err := role.validate(); err != nil {
+			return err
+		}
+	}
+
+	// the username and password are an administrator's unless given a role of their own
+	if server.UserRole == `` {
+		server.UserRole = RoleAdmin
+	} else if _, ok := server.Role(server.UserRole); !ok {
+		return fmt.Errorf("user %s: no such role %q", server.Username, server.UserRole)
+	}
+
+	for _, token := range server.Tokens {
+		if token.Role == `` {
+			token.Role = RoleAdmin
+		} else if _, ok := server.Role(token.Role); !ok {
+			return fmt.Errorf("token %s: no such role %q", token.Name, token.Role)
+		}
+	}
+
 	return nil
 }
 
@@ -78,9 +226,9 @@ func (server *Server) Start() error {
 	}
 
 	// routes not registered below will fallback to the UI server
-	vestigo.CustomNotFoundHandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+	vestigo.CustomNotFoundHandlerFunc(server.require(PermissionRead, func(w http.ResponseWriter, req *http.Request) {
 		ui.ServeHTTP(w, req)
-	})
+	}))
 
 	router.Get(`/api/status`, func(w http.ResponseWriter, req *http.Request) {
 		Respond(w, map[string]any{
@@ -88,62 +236,329 @@ func (server *Server) Start() error {
 		})
 	})
 
-	router.Get(`/api/manager`, func(w http.ResponseWriter, req *http.Request) {
+	router.Get(`/api/manager`, server.require(PermissionRead, func(w http.ResponseWriter, req *http.Request) {
 		Respond(w, server.manager)
-	})
+	}))
 
-	router.Get(`/api/programs`, func(w http.ResponseWriter, req *http.Request) {
-		Respond(w, server.manager.Programs())
-	})
+	// rereads the configuration file and applies any changes; with ?dryrun=true, only reports
+	// what would change
+	router.Put(`/api/manager/reload`, server.require(PermissionAdmin, func(w http.ResponseWriter, req *http.Request) {
+		var diff *ConfigDiff
+		var err error
+
+		if typeutil.Bool(req.URL.Query().Get(`dryrun`)) {
+			diff, err = server.manager.Reread()
+		} else {
+			diff, err = server.manager.Update()
+			server.audit(req, &AuditEntry{Action: `reload`, Error: errorString(err)})
+		}
+
+		if err == nil {
+			Respond(w, diff)
+		} else {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	}))
+
+	// stops all programs and exits
+	router.Put(`/api/manager/shutdown`, server.require(PermissionAdmin, func(w http.ResponseWriter, req *http.Request) {
+		log.Infof("Shutdown requested through the API, stopping all programs...")
+		server.audit(req, &AuditEntry{Action: `shutdown`})
+		go server.manager.Stop(false)
+
+		http.Error(w, ``, http.StatusAccepted)
+	}))
+
+	router.Get(`/api/programs`, server.require(PermissionRead, func(w http.ResponseWriter, req *http.Request) {
+		Respond(w, server.visiblePrograms(req, server.manager.Programs()))
+	}))
 
-	router.Get(`/api/programs/:program`, func(w http.ResponseWriter, req *http.Request) {
+	router.Get(`/api/programs/:program`, server.require(PermissionRead, func(w http.ResponseWriter, req *http.Request) {
 		var name = vestigo.Param(req, `program`)
 
 		if program, ok := server.manager.Program(name); ok {
-			Respond(w, program)
+			if server.authorize(w, req, PermissionRead, program) {
+				Respond(w, program)
+			}
 		} else {
 			http.Error(w, fmt.Sprintf("Program '%s' not found", name), http.StatusNotFound)
 		}
-	})
+	}))
 
-	router.Put(`/api/programs/:program/action/:action`, func(w http.ResponseWriter, req *http.Request) {
+	router.Put(`/api/programs/:program/action/:action`, server.require(PermissionOperate, func(w http.ResponseWriter, req *http.Request) {
 		var name = vestigo.Param(req, `program`)
 		var action = strings.ToLower(vestigo.Param(req, `action`))
 
+		if programs, err := server.manager.Resolve(name); err == nil {
+			if !server.authorize(w, req, PermissionOperate, programs...) {
+				return
+			}
+
+			// actions against a group or a program with multiple instances apply to all of them
+			for _, program := range programs {
+				switch action {
+				case `start`:
+					program.Start()
+					server.audit(req, startAuditEntry(action, program))
+
+				case `stop`:
+					program.Stop()
+					server.audit(req, programAuditEntry(action, program, nil))
+
+				case `restart`:
+					program.Restart()
+					server.audit(req, startAuditEntry(action, program))
+
+				default:
+					http.Error(w, fmt.Sprintf("Unknown action '%s'", action), http.StatusBadRequest)
+					return
+				}
+			}
+
+			http.Error(w, ``, http.StatusNoContent)
+		} else {
+			http.Error(w, fmt.Sprintf("Program '%s' not found", name), http.StatusNotFound)
+		}
+	}))
+
+	// sends a signal (by name or number) to a program; with ?group=true, it is sent to the
+	// program's whole process group
+	router.Put(`/api/programs/:program/signal/:signal`, server.require(PermissionOperate, func(w http.ResponseWriter, req *http.Request) {
+		var name = vestigo.Param(req, `program`)
+
+		if programs, err := server.manager.Resolve(name); err == nil {
+			if server.authorize(w, req, PermissionOperate, programs...) {
+				server.signalPrograms(w, req, programs, false)
+			}
+		} else {
+			http.Error(w, fmt.Sprintf("Program '%s' not found", name), http.StatusNotFound)
+		}
+	}))
+
+	// sends a signal to every running program the request is allowed to control
+	router.Put(`/api/signal/:signal`, server.require(PermissionOperate, func(w http.ResponseWriter, req *http.Request) {
+		var role = server.requestRole(req)
+		var programs = make([]*Program, 0)
+
+		for _, program := range server.manager.Programs() {
+			if role.Allows(PermissionOperate, program) {
+				programs = append(programs, program)
+			}
+		}
+
+		server.signalPrograms(w, req, programs, true)
+	}))
+
+	// writes the request body to the program's stdin (if it was started with stdin=pipe)
+	router.Post(`/api/programs/:program/stdin`, server.require(PermissionOperate, func(w http.ResponseWriter, req *http.Request) {
+		var name = vestigo.Param(req, `program`)
+
 		if program, ok := server.manager.Program(name); ok {
+			if !server.authorize(w, req, PermissionOperate, program) {
+				return
+			}
+
+			var data, err = io.ReadAll(http.MaxBytesReader(w, req.Body, MaxStdinBytes))
+
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+
+			err = program.WriteStdin(data)
+			server.audit(req, programAuditEntry(`stdin`, program, err))
+
+			if err == nil {
+				http.Error(w, ``, http.StatusNoContent)
+			} else if errors.Is(err, ErrNotRunning) {
+				http.Error(w, fmt.Sprintf("Program '%s' is not running", name), http.StatusConflict)
+			} else if errors.Is(err, ErrStdinClosed) {
+				http.Error(w, fmt.Sprintf("Program '%s': %v", name, err), http.StatusConflict)
+			} else if errors.Is(err, ErrStdinTimeout) {
+				http.Error(w, fmt.Sprintf("Program '%s': %v", name, err), http.StatusGatewayTimeout)
+			} else if errors.Is(err, ErrStdinNotEnabled) {
+				http.Error(w, fmt.Sprintf("Program '%s': %v", name, err), http.StatusBadRequest)
+			} else {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+			}
+		} else {
+			http.Error(w, fmt.Sprintf("Program '%s' not found", name), http.StatusNotFound)
+		}
+	}))
+
+	router.Get(`/api/programs/:program/log/:stream`, server.require(PermissionRead, func(w http.ResponseWriter, req *http.Request) {
+		var name = vestigo.Param(req, `program`)
+		var stream = vestigo.Param(req, `stream`)
+		var offset int64 = -DefaultLogTailBytes
+		var length = typeutil.Int(req.URL.Query().Get(`length`))
+
+		if stream != `stdout` && stream != `stderr` {
+			http.Error(w, fmt.Sprintf("Unknown stream '%s'", stream), http.StatusBadRequest)
+			return
+		}
+
+		// an explicit offset of 0 reads from the start of the log, not its tail
+		if req.URL.Query().Has(`offset`) {
+			if o, err := strconv.ParseInt(req.URL.Query().Get(`offset`), 10, 64); err == nil {
+				offset = o
+			} else {
+				http.Error(w, fmt.Sprintf("Invalid offset: %v", err), http.StatusBadRequest)
+				return
+			}
+		}
+
+		if program, ok := server.manager.Program(name); ok {
+			if !server.authorize(w, req, PermissionRead, program) {
+				return
+			} else if chunk, err := program.ReadLog(stream == `stdout`, offset, length); err == nil {
+				Respond(w, chunk)
+			} else if errors.Is(err, ErrNoLogFile) || errors.Is(err, os.ErrNotExist) {
+				http.Error(w, fmt.Sprintf("No %s log file for program '%s'", stream, name), http.StatusNotFound)
+			} else if errors.Is(err, ErrInvalidLogRange) {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+			} else {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+			}
+		} else {
+			http.Error(w, fmt.Sprintf("Program '%s' not found", name), http.StatusNotFound)
+		}
+	}))
+
+	// only groups with at least one visible member are listed
+	router.Get(`/api/groups`, server.require(PermissionRead, func(w http.ResponseWriter, req *http.Request) {
+		var groups = make([]*Group, 0)
+
+		for _, group := range server.manager.Groups() {
+			if len(server.visiblePrograms(req, group.Members())) > 0 {
+				groups = append(groups, group)
+			}
+		}
+
+		Respond(w, groups)
+	}))
+
+	router.Get(`/api/groups/:group`, server.require(PermissionRead, func(w http.ResponseWriter, req *http.Request) {
+		var name = vestigo.Param(req, `group`)
+
+		if group, ok := server.manager.Group(name); ok {
+			if server.authorize(w, req, PermissionRead, group.Members()...) {
+				Respond(w, group)
+			}
+		} else {
+			http.Error(w, fmt.Sprintf("Group '%s' not found", name), http.StatusNotFound)
+		}
+	}))
+
+	router.Put(`/api/groups/:group/action/:action`, server.require(PermissionOperate, func(w http.ResponseWriter, req *http.Request) {
+		var name = vestigo.Param(req, `group`)
+		var action = strings.ToLower(vestigo.Param(req, `action`))
+
+		if group, ok := server.manager.Group(name); ok {
+			if !server.authorize(w, req, PermissionOperate, group.Members()...) {
+				return
+			}
+
 			switch action {
 			case `start`:
-				program.Start()
+				group.Start()
 
 			case `stop`:
-				program.Stop()
+				group.Stop()
 
 			case `restart`:
-				program.Restart()
+				group.Restart()
 
 			default:
 				http.Error(w, fmt.Sprintf("Unknown action '%s'", action), http.StatusBadRequest)
+				return
+			}
+
+			for _, program := range group.Members() {
+				if action == `stop` {
+					server.audit(req, programAuditEntry(action, program, nil))
+				} else {
+					server.audit(req, startAuditEntry(action, program))
+				}
 			}
 
 			http.Error(w, ``, http.StatusNoContent)
 		} else {
-			http.Error(w, fmt.Sprintf("Program '%s' not found", name), http.StatusNotFound)
+			http.Error(w, fmt.Sprintf("Group '%s' not found", name), http.StatusNotFound)
 		}
-	})
+	}))
 
-	serverHandler.UseHandler(router)
+	// sends a signal to the group's running members
+	router.Put(`/api/groups/:group/signal/:signal`, server.require(PermissionOperate, func(w http.ResponseWriter, req *http.Request) {
+		var name = vestigo.Param(req, `group`)
+
+		if group, ok := server.manager.Group(name); ok {
+			if server.authorize(w, req, PermissionOperate, group.Members()...) {
+				server.signalPrograms(w, req, group.Members(), true)
+			}
+		} else {
+			http.Error(w, fmt.Sprintf("Group '%s' not found", name), http.StatusNotFound)
+		}
+	}))
+
+	// entries from the audit log, optionally limited to those recorded ?since and ?until the
+	// given times
+	router.Get(`/api/audit`, server.require(PermissionAdmin, func(w http.ResponseWriter, req *http.Request) {
+		if server.AuditLog == `` {
+			http.Error(w, `Audit log is not enabled`, http.StatusNotFound)
+			return
+		}
+
+		if since, err := parseAuditTime(req.URL.Query().Get(`since`)); err != nil {
+			http.Error(w, fmt.Sprintf("Invalid since: %v", err), http.StatusBadRequest)
+		} else if until, err := parseAuditTime(req.URL.Query().Get(`until`)); err != nil {
+			http.Error(w, fmt.Sprintf("Invalid until: %v", err), http.StatusBadRequest)
+		} else if entries, err := ReadAuditLog(server.AuditLog, since, until); err == nil {
+			Respond(w, entries)
+		} else {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	}))
+
+	// Supervisor-compatible XML-RPC interface (for supervisorctl and friends); permissions are
+	// checked for each method called
+	router.Post(`/RPC2`, server.require(PermissionRead, func(w http.ResponseWriter, req *http.Request) {
+		var rpc = NewRPCInterface(server.manager)
 
-	log.Infof("Running API server at %s", server.Address)
+		rpc.Role = server.requestRole(req)
+		rpc.Audit = func(entry *AuditEntry) {
+			server.audit(req, entry)
+		}
+
+		rpc.ServeHTTP(w, req)
+	}))
+
+	serverHandler.Use(negroni.HandlerFunc(server.requireAuth))
+	serverHandler.UseHandler(router)
 
 	var httpserv = &http.Server{
-		Addr:           server.Address,
 		Handler:        serverHandler,
 		ReadTimeout:    10 * time.Second,
 		WriteTimeout:   10 * time.Second,
 		MaxHeaderBytes: 1 << 20,
 	}
 
-	if err := httpserv.ListenAndServe(); err != nil {
+	if listener, err := server.listen(); err == nil {
+		server.boundLock.Lock()
+		server.bound = listener.Addr()
+		server.boundLock.Unlock()
+
+		if server.TLSEnabled() {
+			listener = tls.NewListener(listener, server.tlsConfig())
+			log.Infof("Running API server at %s (TLS)", server.Address)
+		} else {
+			log.Infof("Running API server at %s", server.Address)
+		}
+
+		if err := httpserv.Serve(listener); err != nil {
+			log.Error(err)
+			return err
+		}
+	} else {
 		log.Error(err)
 		return err
 	}
@@ -151,6 +566,52 @@ func (server *Server) Start() error {
 	return nil
 }
 
+// Sends the signal named in the request to the given programs, responding with a 409 error if
+// any of them aren't running.  If runningOnly is set, programs that aren't running are skipped
+// instead, and the error is only returned if none of them are.
+func (server *Server) signalPrograms(w http.ResponseWriter, req *http.Request, programs []*Program, runningOnly bool) {
+	var signal, err = ParseProgramSignal(vestigo.Param(req, `signal`))
+	var asGroup = typeutil.Bool(req.URL.Query().Get(`group`))
+	var signalled int
+
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	for _, program := range programs {
+		if runningOnly && program.PID() <= 0 {
+			continue
+		}
+
+		var err error
+
+		if asGroup {
+			err = program.SignalGroup(signal)
+		} else {
+			err = program.Signal(signal)
+		}
+
+		server.audit(req, programAuditEntry(`signal `+string(signal), program, err))
+
+		if errors.Is(err, ErrNotRunning) {
+			http.Error(w, fmt.Sprintf("Program '%s' is not running", program.FullName()), http.StatusConflict)
+			return
+		} else if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		signalled += 1
+	}
+
+	if signalled == 0 {
+		http.Error(w, `No programs are running`, http.StatusConflict)
+	} else {
+		http.Error(w, ``, http.StatusNoContent)
+	}
+}
+
 func Respond(w http.ResponseWriter, data any) {
 	w.Header().Set(`Content-Type`, `application/json`)
 
@@ -0,0 +1,708 @@
+package procwatch
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// returns an HTTP client that sends all requests to the given Unix socket
+func socketClient(socket string) *http.Client {
+	return &http.Client{
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _ string, _ string) (net.Conn, error) {
+				return new(net.Dialer).DialContext(ctx, `unix`, socket)
+			},
+		},
+	}
+}
+
+func waitForSocket(socket string) os.FileInfo {
+	for i := 0; i < 50; i++ {
+		if stat, err := os.Stat(socket); err == nil && stat.Mode()&os.ModeSocket != 0 {
+			return stat
+		}
+
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	return nil
+}
+
+func TestUnixSocketServer(t *testing.T) {
+	assert := require.New(t)
+	socket := filepath.Join(t.TempDir(), `procwatch.sock`)
+	t.Setenv(`PROCWATCH_TEST_SOCKET`, socket)
+	t.Setenv(`PROCWATCH_TEST_OWNER`, fmt.Sprintf("%d:%d", os.Getuid(), os.Getgid()))
+
+	// a stale socket left behind by a previous run is replaced
+	stale, err := net.ListenUnix(`unix`, &net.UnixAddr{Name: socket, Net: `unix`})
+	assert.NoError(err)
+	stale.SetUnlinkOnClose(false)
+	assert.NoError(stale.Close())
+
+	manager, err := newManager(`socket`)
+	assert.NoError(err)
+
+	path, ok := manager.Server.SocketPath()
+	assert.True(ok)
+	assert.Equal(socket, path)
+
+	assert.NotNil(waitForSocket(socket))
+
+	// the socket is listening (and so visible) a moment before its mode and owner are set
+	assert.Eventually(func() bool {
+		stat, err := os.Stat(socket)
+		return err == nil && stat.Mode().Perm() == 0660
+	}, 5*time.Second, 10*time.Millisecond)
+
+	stat, err := os.Stat(socket)
+	assert.NoError(err)
+	assert.Equal(os.ModeSocket, stat.Mode().Type())
+	assert.EqualValues(os.Getuid(), stat.Sys().(*syscall.Stat_t).Uid)
+
+	response, err := socketClient(socket).Get(`http://localhost/api/status`)
+	assert.NoError(err)
+	defer response.Body.Close()
+
+	body, err := io.ReadAll(response.Body)
+	assert.NoError(err)
+	assert.Equal(http.StatusOK, response.StatusCode)
+	assert.Contains(string(body), Version)
+
+	// only one server may listen on a socket
+	_, err = manager.Server.listen()
+	assert.ErrorContains(err, `already listening`)
+
+	// and anything that isn't a socket is left alone
+	var other = filepath.Join(t.TempDir(), `procwatch.sock`)
+	assert.NoError(os.WriteFile(other, []byte(`important`), 0600))
+
+	_, err = (&Server{Address: `unix://` + other}).listen()
+	assert.ErrorContains(err, `not a socket`)
+
+	data, err := os.ReadFile(other)
+	assert.NoError(err)
+	assert.Equal(`important`, string(data))
+}
+
+func TestServerAuth(t *testing.T) {
+	assert := require.New(t)
+	socket := filepath.Join(t.TempDir(), `procwatch.sock`)
+	t.Setenv(`PROCWATCH_TEST_SOCKET`, socket)
+
+	manager, err := newManager(`auth`)
+	assert.NoError(err)
+	assert.True(manager.Server.AuthEnabled())
+
+	var request = serverRequester(assert, manager.Server)
+
+	var get = func(path string, auth func(*http.Request)) (int, string) {
+		return request(http.MethodGet, path, ``, auth)
+	}
+
+	code, _ := get(`/api/programs`, nil)
+	assert.Equal(http.StatusUnauthorized, code)
+
+	code, _ = get(`/api/programs`, basicAuth(`admin`, `wrong`))
+	assert.Equal(http.StatusUnauthorized, code)
+
+	code, _ = get(`/api/programs`, bearerAuth(`wrong`))
+	assert.Equal(http.StatusUnauthorized, code)
+
+	// the status endpoint is configured to be open
+	code, _ = get(`/api/status`, nil)
+	assert.Equal(http.StatusOK, code)
+
+	code, _ = get(`/api/programs`, basicAuth(`admin`, `secret`))
+	assert.Equal(http.StatusOK, code)
+
+	code, _ = get(`/api/programs`, bearerAuth(`0123456789abcdef`))
+	assert.Equal(http.StatusOK, code)
+
+	// tokens also work as a password, so they can be entered into a browser's login prompt
+	code, _ = get(`/api/programs`, basicAuth(`anyone`, `0123456789abcdef`))
+	assert.Equal(http.StatusOK, code)
+
+	// credentials are never exposed
+	code, body := get(`/api/manager`, basicAuth(`admin`, `secret`))
+	assert.Equal(http.StatusOK, code)
+	assert.Contains(body, `"username":"admin"`)
+	assert.NotContains(body, `{SHA}`)
+	assert.NotContains(body, `0123456789abcdef`)
+
+	// the user is an admin unless given a role of its own
+	code, _ = get(`/api/audit`, basicAuth(`admin`, `secret`))
+	assert.Equal(http.StatusNotFound, code)
+}
+
+func TestServerUserRole(t *testing.T) {
+	assert := require.New(t)
+	t.Setenv(`PROCWATCH_TEST_SOCKET`, filepath.Join(t.TempDir(), `procwatch.sock`))
+
+	manager, err := newManager(`readonly`)
+	assert.NoError(err)
+	assert.Equal(RoleReadOnly, manager.Server.UserRole)
+
+	var request = serverRequester(assert, manager.Server)
+
+	code, _ := request(http.MethodGet, `/api/audit`, ``, basicAuth(`admin`, `secret`))
+	assert.Equal(http.StatusForbidden, code)
+
+	code, _ = request(http.MethodGet, `/api/programs`, ``, basicAuth(`admin`, `secret`))
+	assert.Equal(http.StatusOK, code)
+
+	assert.ErrorContains((&Server{Username: `admin`, UserRole: `bogus`}).Initialize(manager), `no such role`)
+}
+
+func TestServerRoles(t *testing.T) {
+	assert := require.New(t)
+	socket := filepath.Join(t.TempDir(), `procwatch.sock`)
+	t.Setenv(`PROCWATCH_TEST_SOCKET`, socket)
+
+	t.Setenv(`PROCWATCH_TEST_AUDITLOG`, filepath.Join(t.TempDir(), `audit.log`))
+
+	manager, err := newManager(`roles`)
+	assert.NoError(err)
+
+	var request = serverRequester(assert, manager.Server)
+
+	var do = func(method string, path string, token string) (int, string) {
+		if token == `` {
+			return request(method, path, ``, basicAuth(`admin`, `secret`))
+		} else {
+			return request(method, path, ``, bearerAuth(token))
+		}
+	}
+
+	role, ok := manager.Server.Role(`web-oncall`)
+	assert.True(ok)
+	assert.True(role.Can(PermissionOperate))
+	assert.False(role.Can(PermissionAdmin))
+
+	// read-only tokens can see everything, but not change anything
+	code, body := do(http.MethodGet, `/api/programs`, `viewer-token`)
+	assert.Equal(http.StatusOK, code)
+	assert.Contains(body, `"solo"`)
+
+	code, _ = do(http.MethodPut, `/api/programs/solo/action/stop`, `viewer-token`)
+	assert.Equal(http.StatusForbidden, code)
+
+	// operators limited to a group only see and control that group's programs
+	code, body = do(http.MethodGet, `/api/programs`, `oncall-token`)
+	assert.Equal(http.StatusOK, code)
+	assert.Contains(body, `"app"`)
+	assert.Contains(body, `"api"`)
+	assert.NotContains(body, `"solo"`)
+
+	code, _ = do(http.MethodGet, `/api/programs/solo`, `oncall-token`)
+	assert.Equal(http.StatusForbidden, code)
+
+	code, _ = do(http.MethodPut, `/api/programs/web:app/action/stop`, `oncall-token`)
+	assert.Equal(http.StatusNoContent, code)
+
+	code, _ = do(http.MethodPut, `/api/groups/web/action/stop`, `oncall-token`)
+	assert.Equal(http.StatusNoContent, code)
+
+	code, _ = do(http.MethodPut, `/api/programs/solo/action/stop`, `oncall-token`)
+	assert.Equal(http.StatusForbidden, code)
+
+	// only admins can reload the configuration
+	code, _ = do(http.MethodPut, `/api/manager/reload?dryrun=true`, `oncall-token`)
+	assert.Equal(http.StatusForbidden, code)
+
+	code, _ = do(http.MethodPut, `/api/manager/reload?dryrun=true`, ``)
+	assert.Equal(http.StatusOK, code)
+
+	code, _ = request(http.MethodPut, `/api/programs/solo/action/start`, ``)
+	assert.Equal(http.StatusUnauthorized, code)
+
+	// denied changes are audited, denied reads are not
+	entries, err := ReadAuditLog(manager.Server.AuditLog, time.Time{}, time.Time{})
+	assert.NoError(err)
+
+	var denied []*AuditEntry
+
+	for _, entry := range entries {
+		if entry.Action == `denied` {
+			denied = append(denied, entry)
+		}
+	}
+
+	assert.Len(denied, 4)
+	assert.Equal(`viewer`, denied[0].Principal)
+	assert.Equal(`PUT /api/programs/solo/action/stop`, denied[0].Request)
+	assert.Equal(`Forbidden`, denied[0].Error)
+	assert.Equal(`oncall`, denied[1].Principal)
+	assert.Contains(denied[1].Error, `no access to program 'solo'`)
+	assert.Equal(`PUT /api/manager/reload`, denied[2].Request)
+	assert.Empty(denied[3].Principal)
+	assert.Equal(`PUT /api/programs/solo/action/start`, denied[3].Request)
+	assert.Equal(`Unauthorized`, denied[3].Error)
+}
+
+// Waits for the given server to be listening.
+func waitForServer(assert *require.Assertions, server *Server) {
+	assert.Eventually(func() bool {
+		server.boundLock.Lock()
+		defer server.boundLock.Unlock()
+
+		return server.bound != nil
+	}, 5*time.Second, 10*time.Millisecond, `server never started listening`)
+}
+
+// Waits for the given server to be listening, returning a function that sends requests to it
+// (over its Unix socket, if it has one) and returns the status and body of the response.  Any
+// auth functions given with a request are called on it before it is sent, e.g. to add
+// credentials.
+func serverRequester(assert *require.Assertions, server *Server) func(method string, path string, body string, auth ...func(*http.Request)) (int, string) {
+	waitForServer(assert, server)
+
+	var base = server.URL()
+	var client = http.DefaultClient
+
+	if socket, ok := server.SocketPath(); ok {
+		base = `http://localhost`
+		client = socketClient(socket)
+	}
+
+	return func(method string, path string, body string, auth ...func(*http.Request)) (int, string) {
+		req, err := http.NewRequest(method, base+path, strings.NewReader(body))
+		assert.NoError(err)
+
+		for _, fn := range auth {
+			if fn != nil {
+				fn(req)
+			}
+		}
+
+		response, err := client.Do(req)
+		assert.NoError(err)
+		defer response.Body.Close()
+
+		data, err := io.ReadAll(response.Body)
+		assert.NoError(err)
+
+		return response.StatusCode, string(data)
+	}
+}
+
+// returns an auth function for serverRequester that adds the given Basic auth credentials
+func basicAuth(username string, password string) func(*http.Request) {
+	return func(req *http.Request) {
+		req.SetBasicAuth(username, password)
+	}
+}
+
+// returns an auth function for serverRequester that adds the given bearer token
+func bearerAuth(token string) func(*http.Request) {
+	return func(req *http.Request) {
+		req.Header.Set(`Authorization`, `Bearer `+token)
+	}
+}
+
+// writes a new certificate and key signed by the given CA (or self-signed, if ca is nil) to
+// dir/name.crt and dir/name.key
+func writeCertificate(assert *require.Assertions, dir string, name string, ca *tls.Certificate) *tls.Certificate {
+	var key, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(err)
+
+	var template = &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		DNSNames:     []string{`localhost`},
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+
+	var parent, signer = template, any(key)
+
+	if ca == nil {
+		template.IsCA = true
+		template.BasicConstraintsValid = true
+	} else {
+		parent, signer = ca.Leaf, ca.PrivateKey
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, signer)
+	assert.NoError(err)
+
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	assert.NoError(err)
+
+	assert.NoError(os.WriteFile(filepath.Join(dir, name+`.crt`), pem.EncodeToMemory(&pem.Block{Type: `CERTIFICATE`, Bytes: der}), 0600))
+	assert.NoError(os.WriteFile(filepath.Join(dir, name+`.key`), pem.EncodeToMemory(&pem.Block{Type: `EC PRIVATE KEY`, Bytes: keyDer}), 0600))
+
+	certificate, err := tls.LoadX509KeyPair(filepath.Join(dir, name+`.crt`), filepath.Join(dir, name+`.key`))
+	assert.NoError(err)
+
+	certificate.Leaf, err = x509.ParseCertificate(der)
+	assert.NoError(err)
+
+	return &certificate
+}
+
+func TestServerTLS(t *testing.T) {
+	assert := require.New(t)
+	dir := t.TempDir()
+
+	ca := writeCertificate(assert, dir, `ca`, nil)
+	writeCertificate(assert, dir, `server`, ca)
+	clientCert := writeCertificate(assert, dir, `client`, ca)
+
+	server := &Server{
+		Address:     `127.0.0.1:0`,
+		TLSCert:     filepath.Join(dir, `server.crt`),
+		TLSKey:      filepath.Join(dir, `server.key`),
+		TLSClientCA: filepath.Join(dir, `ca.crt`),
+	}
+
+	assert.NoError(server.Initialize(NewManager()))
+	assert.True(server.MutualTLS())
+	go server.Start()
+	waitForServer(assert, server)
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.Leaf)
+
+	var get = func(certificates ...tls.Certificate) (*x509.Certificate, error) {
+		var client = &http.Client{
+			Transport: &http.Transport{
+				TLSClientConfig: &tls.Config{
+					RootCAs:      roots,
+					Certificates: certificates,
+				},
+			},
+		}
+
+		response, err := client.Get(server.URL() + `/api/status`)
+
+		if err != nil {
+			return nil, err
+		}
+
+		defer response.Body.Close()
+		assert.Equal(http.StatusOK, response.StatusCode)
+
+		return response.TLS.PeerCertificates[0], nil
+	}
+
+	// clients without a certificate are turned away
+	_, err := get()
+	assert.Error(err)
+
+	served, err := get(*clientCert)
+	assert.NoError(err)
+	assert.Equal(`server`, served.Subject.CommonName)
+
+	// replacing the certificate and reloading takes effect for new connections
+	writeCertificate(assert, dir, `server`, ca)
+	assert.NoError(server.ReloadTLS())
+
+	reloaded, err := get(*clientCert)
+	assert.NoError(err)
+	assert.NotEqual(served.SerialNumber, reloaded.SerialNumber)
+
+	// mismatched options are rejected
+	assert.Error((&Server{TLSCert: server.TLSCert}).Initialize(NewManager()))
+	assert.Error((&Server{TLSClientCA: server.TLSClientCA}).Initialize(NewManager()))
+}
+
+func TestServerAudit(t *testing.T) {
+	assert := require.New(t)
+	t.Setenv(`PROCWATCH_TEST_AUDITLOG`, filepath.Join(t.TempDir(), `audit.log`))
+
+	manager, err := newManager(`audit`)
+	assert.NoError(err)
+
+	var request = serverRequester(assert, manager.Server)
+
+	var do = func(method string, path string) (int, string) {
+		return request(method, path, ``)
+	}
+
+	var before = time.Now()
+
+	code, _ := do(http.MethodPut, `/api/programs/solo/action/stop`)
+	assert.Equal(http.StatusNoContent, code)
+
+	code, _ = do(http.MethodPut, `/api/programs/solo/signal/HUP`)
+	assert.Equal(http.StatusConflict, code)
+
+	// reads aren't audited
+	code, _ = do(http.MethodGet, `/api/programs/solo`)
+	assert.Equal(http.StatusOK, code)
+
+	entries, err := ReadAuditLog(manager.Server.AuditLog, before, time.Time{})
+	assert.NoError(err)
+	assert.Len(entries, 2)
+	assert.Equal(`stop`, entries[0].Action)
+	assert.Equal(`solo`, entries[0].Program)
+	assert.Equal(ProgramStopped, entries[0].State)
+	assert.NotEmpty(entries[0].RemoteAddr)
+	assert.Empty(entries[0].Error)
+	assert.Equal(`signal HUP`, entries[1].Action)
+	assert.Contains(entries[1].Error, `not running`)
+
+	code, body := do(http.MethodGet, `/api/audit?since=1m`)
+	assert.Equal(http.StatusOK, code)
+	assert.Contains(body, `"action":"signal HUP"`)
+
+	code, body = do(http.MethodGet, `/api/audit?until=`+before.Add(-time.Minute).Format(time.RFC3339))
+	assert.Equal(http.StatusOK, code)
+	assert.Equal("[]\n", body)
+
+	code, _ = do(http.MethodGet, `/api/audit?since=yesterday`)
+	assert.Equal(http.StatusBadRequest, code)
+
+	// handle events without running the manager, which would start solo itself
+	go manager.startEventLogger()
+
+	// starts record why the program couldn't be started
+	solo, _ := manager.Program(`solo`)
+	solo.Directory = `/nonexistent/directory`
+	before = time.Now()
+
+	code, _ = do(http.MethodPut, `/api/programs/solo/action/start`)
+	assert.Equal(http.StatusNoContent, code)
+
+	entries, err = ReadAuditLog(manager.Server.AuditLog, before, time.Time{})
+	assert.NoError(err)
+	assert.Len(entries, 1)
+	assert.Equal(`start`, entries[0].Action)
+	assert.Equal(ProgramFatal, entries[0].State)
+	assert.Contains(entries[0].Error, `directory does not exist`)
+
+	stopAndVerifyManager(manager, assert)
+}
+
+func TestServerSignals(t *testing.T) {
+	assert := require.New(t)
+	t.Setenv(`PROCWATCH_TEST_LOGDIR`, t.TempDir())
+
+	for name, expected := range map[string]ProgramSignal{
+		`hup`:     SIGHUP,
+		`SIGUSR1`: SIGUSR1,
+		`1`:       SIGHUP,
+		`34`:      ProgramSignal(`34`),
+	} {
+		signal, err := ParseProgramSignal(name)
+		assert.NoError(err)
+		assert.Equal(expected, signal)
+	}
+
+	_, err := ParseProgramSignal(`BOGUS`)
+	assert.Error(err)
+
+	manager, err := newManager(`forward`)
+	assert.NoError(err)
+
+	var signalled = make(chan string, 10)
+
+	manager.AddEventHandler(func(event *Event) {
+		if event.HasName(`PROCESS_SIGNAL`) {
+			signalled <- event.Label + ` ` + event.Payload()
+		}
+	})
+
+	var request = serverRequester(assert, manager.Server)
+
+	go manager.Run()
+
+	ignored, _ := manager.Program(`ignored`)
+	forwarded, _ := manager.Program(`forwarded`)
+	waitForState(assert, ProgramRunning, ignored, forwarded)
+
+	// the script reports when it has set up its signal traps
+	assert.Eventually(func() bool {
+		data, _ := os.ReadFile(ignored.LogfilePath(true))
+		return strings.Contains(string(data), "ready\n")
+	}, 5*time.Second, 10*time.Millisecond)
+
+	var put = func(path string) int {
+		code, _ := request(http.MethodPut, path, ``)
+		return code
+	}
+
+	assert.Equal(http.StatusNoContent, put(`/api/programs/ignored/signal/1`))
+	assert.Contains(<-signalled, `signal:HUP`)
+
+	assert.Equal(http.StatusNoContent, put(`/api/programs/ignored:*/signal/USR1?group=true`))
+	assert.Contains(<-signalled, `group:true`)
+
+	assert.Equal(http.StatusBadRequest, put(`/api/programs/ignored/signal/BOGUS`))
+
+	// the traps only run between the script's sleeps
+	assert.Eventually(func() bool {
+		data, _ := os.ReadFile(ignored.LogfilePath(true))
+		return strings.Contains(string(data), "got HUP\n") && strings.Contains(string(data), "got USR1\n")
+	}, 5*time.Second, 10*time.Millisecond)
+
+	ignored.Stop()
+
+	// stopped programs can't be signalled directly, and are skipped when signalling everything
+	assert.Equal(http.StatusConflict, put(`/api/programs/ignored/signal/HUP`))
+	assert.Equal(http.StatusNoContent, put(`/api/signal/HUP`))
+	assert.True(strings.HasPrefix(<-signalled, `forwarded processname:forwarded`))
+
+	stopAndVerifyManager(manager, assert)
+}
+
+func TestServerStdin(t *testing.T) {
+	assert := require.New(t)
+	t.Setenv(`PROCWATCH_TEST_LOGDIR`, t.TempDir())
+
+	manager, err := newManager(`stdin`)
+	assert.NoError(err)
+
+	var request = serverRequester(assert, manager.Server)
+
+	go manager.Run()
+
+	piped, _ := manager.Program(`piped`)
+	closed, _ := manager.Program(`closed`)
+	waitForState(assert, ProgramRunning, piped, closed)
+
+	var post = func(name string, body string) (int, string) {
+		return request(http.MethodPost, `/api/programs/`+name+`/stdin`, body)
+	}
+
+	code, _ := post(`piped`, "hello\n")
+	assert.Equal(http.StatusNoContent, code)
+
+	code, body := post(`closed`, "hello\n")
+	assert.Equal(http.StatusBadRequest, code)
+	assert.Contains(body, `stdin=pipe`)
+
+	// and over XML-RPC
+	_, err = NewRPCInterface(manager).Call(`supervisor.sendProcessStdin`, []any{`piped`, "world\n"})
+	assert.NoError(err)
+
+	assert.Eventually(func() bool {
+		data, _ := os.ReadFile(piped.LogfilePath(true))
+		return strings.Contains(string(data), " hello\n") && strings.Contains(string(data), " world\n")
+	}, 5*time.Second, 10*time.Millisecond)
+
+	piped.Stop()
+
+	code, body = post(`piped`, "again\n")
+	assert.Equal(http.StatusConflict, code)
+	assert.Contains(body, `not running`)
+
+	stopAndVerifyManager(manager, assert)
+}
+
+func TestServerStdinErrors(t *testing.T) {
+	assert := require.New(t)
+	t.Setenv(`PROCWATCH_TEST_LOGDIR`, t.TempDir())
+
+	defer func(timeout time.Duration) {
+		StdinWriteTimeout = timeout
+	}(StdinWriteTimeout)
+
+	StdinWriteTimeout = 100 * time.Millisecond
+
+	manager, err := newManager(`stdin`)
+	assert.NoError(err)
+
+	var request = serverRequester(assert, manager.Server)
+
+	go manager.Run()
+
+	deaf, _ := manager.Program(`deaf`)
+	hungup, _ := manager.Program(`hungup`)
+	waitForState(assert, ProgramRunning, deaf, hungup)
+
+	// more than a pipe holds, to a process that never reads it
+	code, body := request(http.MethodPost, `/api/programs/deaf/stdin`, strings.Repeat("x", 1<<17))
+	assert.Equal(http.StatusGatewayTimeout, code)
+	assert.Contains(body, `timed out`)
+
+	code, body = request(http.MethodPost, `/api/programs/hungup/stdin`, "hello\n")
+	assert.Equal(http.StatusConflict, code)
+	assert.Contains(body, `closed its stdin`)
+
+	stopAndVerifyManager(manager, assert)
+}
+
+func TestServerURL(t *testing.T) {
+	assert := require.New(t)
+
+	assert.Equal(`unix:///tmp/procwatch.sock`, (&Server{Address: `unix:///tmp/procwatch.sock`}).URL())
+	assert.Equal(`http://localhost:9001`, (&Server{Address: `:9001`}).URL())
+	assert.Equal(`http://localhost:9001`, (&Server{Address: `0.0.0.0:9001`}).URL())
+	assert.Equal(`http://localhost:9001`, (&Server{Address: `[::]:9001`}).URL())
+	assert.Equal(`http://10.0.0.1:9001`, (&Server{Address: `10.0.0.1:9001`}).URL())
+	assert.Equal(`https://example.com:9001`, (&Server{Address: `example.com:9001`, TLSCert: `cert.pem`, TLSKey: `key.pem`}).URL())
+}
+
+func TestServerLogOffset(t *testing.T) {
+	assert := require.New(t)
+	t.Setenv(`PROCWATCH_TEST_LOGDIR`, t.TempDir())
+
+	manager, err := newManager(`stdin`)
+	assert.NoError(err)
+
+	var request = serverRequester(assert, manager.Server)
+
+	go manager.Run()
+
+	piped, _ := manager.Program(`piped`)
+	waitForState(assert, ProgramRunning, piped)
+
+	assert.NoError(piped.WriteStdin([]byte("first\n" + strings.Repeat("filler\n", 2*DefaultLogTailBytes/7))))
+
+	var read = func(query string) (int, LogChunk) {
+		var chunk LogChunk
+		code, body := request(http.MethodGet, `/api/programs/piped/log/stdout`+query, ``)
+
+		if code == http.StatusOK {
+			assert.NoError(json.Unmarshal([]byte(body), &chunk))
+		}
+
+		return code, chunk
+	}
+
+	assert.Eventually(func() bool {
+		_, chunk := read(``)
+		return chunk.Size > 2*DefaultLogTailBytes
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// without an offset, the tail of the log is read
+	code, chunk := read(``)
+	assert.Equal(http.StatusOK, code)
+	assert.NotContains(chunk.Data, "first\n")
+	assert.Len(chunk.Data, DefaultLogTailBytes)
+
+	// ...but an explicit offset of 0 reads from the start
+	code, chunk = read(`?offset=0`)
+	assert.Equal(http.StatusOK, code)
+	assert.Contains(chunk.Data, " first\n")
+
+	code, _ = read(`?offset=nope`)
+	assert.Equal(http.StatusBadRequest, code)
+
+	stopAndVerifyManager(manager, assert)
+}
@@ -0,0 +1,86 @@
+package procwatch
+
+import (
+	"errors"
+
+	"github.com/ghetzel/go-stockutil/log"
+	"github.com/natefinch/lumberjack"
+)
+
+// Closes the manager's log file and the log files of all programs so that they are reopened
+// on the next write.  This is what SIGUSR2 does, and allows log files to be moved aside by
+// tools like logrotate.
+func (manager *Manager) ReopenLogs() error {
+	var merr error
+
+	if manager.rollingLogger != nil {
+		if err := manager.rollingLogger.Close(); err != nil {
+			merr = log.AppendError(merr, err)
+		}
+	}
+
+	for _, program := range manager.Programs() {
+		if err := program.reopenLogs(); err != nil {
+			merr = log.AppendError(merr, err)
+		}
+	}
+
+	return merr
+}
+
+func (program *Program) reopenLogs() error {
+	var merr error
+
+	program.processLock.Lock()
+	defer program.processLock.Unlock()
+
+	for i, logger := range []*lumberjack.Logger{program.stdoutLogger, program.stderrLogger} {
+		if logger == nil {
+			continue
+		}
+
+		if err := logger.Close(); err != nil {
+			merr = log.AppendError(merr, err)
+		}
+
+		var configured = program.StderrLogfile
+
+		if i == 0 {
+			configured = program.StdoutLogfile
+		}
+
+		// recreate automatically-named logs now so they keep belonging to the program's user
+		if configured == `AUTO` {
+			if err := program.chownLogfile(logger.Filename); err != nil {
+				merr = log.AppendError(merr, err)
+			}
+		}
+	}
+
+	return merr
+}
+
+// Sends the given signal to every running program that lists it in its "forward_signals"
+// option.
+func (manager *Manager) ForwardSignal(signal ProgramSignal) {
+	for _, program := range manager.Programs() {
+		if program.forwards(signal) {
+			log.Debugf("[%s] Forwarding SIG%s", program.Name, signal)
+
+			if err := program.Signal(signal); err != nil && !errors.Is(err, ErrNotRunning) {
+				log.Warningf("[%s] Failed to forward SIG%s: %v", program.Name, signal, err)
+			}
+		}
+	}
+}
+
+// returns whether the program wants the given signal forwarded to it
+func (program *Program) forwards(signal ProgramSignal) bool {
+	for _, name := range program.ForwardSignals {
+		if s, err := ParseProgramSignal(name); err == nil && s == signal {
+			return true
+		}
+	}
+
+	return false
+}
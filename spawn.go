@@ -0,0 +1,102 @@
+package procwatch
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"syscall"
+)
+
+var ErrDirectoryNotExist = errors.New(`directory does not exist`)
+var ErrNotDirectory = errors.New(`not a directory`)
+var ErrPermissionDenied = errors.New(`permission denied`)
+var ErrCommandNotFound = errors.New(`command not found`)
+
+// Returns whether the given error from starting a program is one that retrying won't fix,
+// in which case the program goes straight to FATAL.
+func isPermanentSpawnError(err error) bool {
+	for _, permanent := range []error{
+		ErrCannotSwitchUser,
+		ErrCommandNotFound,
+		ErrDirectoryNotExist,
+		ErrNotDirectory,
+		ErrPermissionDenied,
+	} {
+		if errors.Is(err, permanent) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Verifies that dir exists and can be entered by the user described by credential (or by
+// procwatch itself if credential is nil).
+func checkDirectory(dir string, credential *syscall.Credential) error {
+	if stat, err := os.Stat(dir); err == nil {
+		if !stat.IsDir() {
+			return fmt.Errorf("%w: %s", ErrNotDirectory, dir)
+		}
+
+		if credential == nil {
+			if err := syscall.Access(dir, 0x1); err != nil {
+				return fmt.Errorf("%w: %s", ErrPermissionDenied, dir)
+			}
+		} else if sys, ok := stat.Sys().(*syscall.Stat_t); ok {
+			var mode = stat.Mode().Perm()
+			var allowed bool
+
+			switch {
+			case credential.Uid == 0:
+				allowed = true
+			case sys.Uid == credential.Uid:
+				allowed = (mode&0100 != 0)
+			case sys.Gid == credential.Gid || slices.Contains(credential.Groups, sys.Gid):
+				allowed = (mode&0010 != 0)
+			default:
+				allowed = (mode&0001 != 0)
+			}
+
+			if !allowed {
+				return fmt.Errorf("%w: %s", ErrPermissionDenied, dir)
+			}
+		}
+
+		return nil
+	} else if os.IsNotExist(err) {
+		return fmt.Errorf("%w: %s", ErrDirectoryNotExist, dir)
+	} else if os.IsPermission(err) {
+		return fmt.Errorf("%w: %s", ErrPermissionDenied, dir)
+	} else {
+		return err
+	}
+}
+
+// Verifies that name can be executed, either as a path (relative to dir, if it isn't absolute)
+// or by searching the PATH.
+func checkCommand(name string, dir string) error {
+	if strings.Contains(name, `/`) && !filepath.IsAbs(name) && dir != `` {
+		name = filepath.Join(dir, name)
+	}
+
+	if _, err := exec.LookPath(name); err != nil && !errors.Is(err, exec.ErrDot) {
+		return fmt.Errorf("%w: %s", ErrCommandNotFound, name)
+	}
+
+	return nil
+}
+
+// Go can't set the umask of a child process directly, so commands with a umask are run
+// through a shell that sets it and then replaces itself with the real command.  The
+// process keeps the same PID across the exec.
+func umaskShim(umask int, words []string) []string {
+	return append([]string{
+		`/bin/sh`,
+		`-c`,
+		fmt.Sprintf(`umask %04o && exec "$0" "$@"`, umask),
+	}, words...)
+}
@@ -0,0 +1,98 @@
+package procwatch
+
+import (
+	"errors"
+	"os"
+	"syscall"
+	"time"
+)
+
+// The value of a program's "stdin" option that gives its process a pipe for WriteStdin.
+const StdinPipe = `pipe`
+
+// How long WriteStdin waits for a process to read what it was given before giving up.
+var StdinWriteTimeout = 5 * time.Second
+
+var ErrStdinNotEnabled = errors.New(`stdin is not enabled for this program (set stdin=pipe)`)
+var ErrStdinTimeout = errors.New(`timed out writing to stdin (the process isn't reading it)`)
+var ErrStdinClosed = errors.New(`the process has closed its stdin`)
+
+// Returns whether the program's process is started with a pipe on its stdin.  Event listeners
+// always are, but the pipe carries events and can't be written to.
+func (program *Program) StdinEnabled() bool {
+	return program.Stdin == StdinPipe && program.listener == nil
+}
+
+// Writes data to the stdin of the program's running process.  Concurrent writes are
+// serialized, so data from one is never interleaved with another's.
+func (program *Program) WriteStdin(data []byte) error {
+	if !program.StdinEnabled() {
+		return ErrStdinNotEnabled
+	} else if program.PID() <= 0 {
+		return ErrNotRunning
+	}
+
+	program.processLock.Lock()
+	var stdin = program.stdin
+	program.processLock.Unlock()
+
+	if stdin == nil {
+		return ErrNotRunning
+	}
+
+	program.stdinLock.Lock()
+	defer program.stdinLock.Unlock()
+
+	if err := stdin.SetWriteDeadline(time.Now().Add(StdinWriteTimeout)); err != nil {
+		return stdinError(err)
+	}
+
+	_, err := stdin.Write(data)
+	return stdinError(err)
+}
+
+func stdinError(err error) error {
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, os.ErrDeadlineExceeded):
+		return ErrStdinTimeout
+	case errors.Is(err, syscall.EPIPE):
+		return ErrStdinClosed
+	case errors.Is(err, os.ErrClosed):
+		// the process exited while we were writing
+		return ErrNotRunning
+	default:
+		return err
+	}
+}
+
+// Creates the pipe a process is started with when stdin=pipe.  The write end is kept for
+// WriteStdin until the process exits; the read end belongs to the process, and should be
+// closed once it has started.
+func (program *Program) openStdin() (*os.File, *os.File, error) {
+	var stdinR, stdinW, err = os.Pipe()
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	program.processLock.Lock()
+	program.stdin = stdinW
+	program.processLock.Unlock()
+
+	return stdinR, stdinW, nil
+}
+
+// Closes the write end of a stdin pipe once its process has exited.
+func (program *Program) closeStdin(stdinW *os.File) {
+	stdinW.Close()
+
+	program.processLock.Lock()
+	defer program.processLock.Unlock()
+
+	// the program may already have been restarted with a new pipe
+	if program.stdin == stdinW {
+		program.stdin = nil
+	}
+}
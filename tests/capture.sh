@@ -0,0 +1,10 @@
+#!/bin/sh
+# Reports results in capture mode, on both stdout and stderr.
+echo "before"
+echo "<!--XSUPERVISOR:BEGIN-->"
+echo "result: 42"
+echo "<!--XSUPERVISOR:END-->"
+echo "after <!--XSUPERVISOR:BEGIN-->inline<!--XSUPERVISOR:END--> done"
+echo "<!--XSUPERVISOR:BEGIN-->truncated<!--XSUPERVISOR:END-->" >&2
+
+exec sleep 60
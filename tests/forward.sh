@@ -0,0 +1,10 @@
+#!/bin/sh
+# Reports the signals it receives until it is stopped.
+trap 'echo "got HUP"' HUP
+trap 'echo "got USR1"' USR1
+
+echo "ready"
+
+while true; do
+    sleep 0.1
+done
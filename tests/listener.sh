@@ -0,0 +1,10 @@
+#!/bin/sh
+# A minimal event listener: records each event it receives to $LISTENER_OUT and acknowledges it.
+while true; do
+    printf 'READY\n'
+    read -r header || exit 0
+    len="${header##*len:}"
+    payload="$(dd bs=1 count="$len" 2> /dev/null)"
+    echo "${header} ${payload}" >> "$LISTENER_OUT"
+    printf 'RESULT 2\nOK'
+done
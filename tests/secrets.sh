@@ -0,0 +1,7 @@
+#!/bin/sh
+# Reports the secrets it was given in its arguments and environment.
+echo "arg=$1 password=$DB_PASSWORD token=$TOKEN"
+
+while true; do
+    sleep 0.1
+done
@@ -0,0 +1,17 @@
+#!/bin/sh
+# Runs until stopped.  With "trap", exits cleanly on SIGINT; with "ignore", ignores SIGTERM so
+# that it has to be killed.
+sleep 30 > /dev/null 2>&1 &
+child=$!
+
+case "$1" in
+trap)
+    trap 'echo "caught INT"; kill $child; exit 0' INT
+    ;;
+ignore)
+    trap '' TERM
+    ;;
+esac
+
+echo "waiting"
+wait
@@ -0,0 +1,101 @@
+package procwatch
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"sync"
+)
+
+// The certificate and client CA pool the server is currently using.  These are swapped out
+// as a unit when the files are reloaded, so in-flight handshakes always see a matching pair.
+type tlsState struct {
+	lock        sync.RWMutex
+	certificate *tls.Certificate
+	clientCAs   *x509.CertPool
+}
+
+// Returns whether the server is configured to serve HTTPS.
+func (server *Server) TLSEnabled() bool {
+	return server.TLSCert != ``
+}
+
+// Returns whether clients must present a certificate signed by the configured client CA.
+func (server *Server) MutualTLS() bool {
+	return server.TLSEnabled() && server.TLSClientCA != ``
+}
+
+func (server *Server) validateTLS() error {
+	if server.TLSCert != `` && server.TLSKey == `` {
+		return fmt.Errorf("tls_cert requires tls_key to be set")
+	} else if server.TLSKey != `` && server.TLSCert == `` {
+		return fmt.Errorf("tls_key requires tls_cert to be set")
+	} else if server.TLSClientCA != `` && server.TLSCert == `` {
+		return fmt.Errorf("tls_client_ca requires tls_cert and tls_key to be set")
+	} else if _, ok := server.SocketPath(); ok && server.TLSEnabled() {
+		return fmt.Errorf("TLS cannot be used with a Unix socket")
+	}
+
+	return nil
+}
+
+// Rereads the server's certificate, key, and client CA bundle from disk.  Connections made
+// after this returns use the new files; if any of them can't be loaded, the ones already in
+// use are kept.
+func (server *Server) ReloadTLS() error {
+	if !server.TLSEnabled() {
+		return nil
+	}
+
+	var clientCAs *x509.CertPool
+	var certificate, err = tls.LoadX509KeyPair(server.TLSCert, server.TLSKey)
+
+	if err != nil {
+		return fmt.Errorf("tls: %v", err)
+	}
+
+	if server.TLSClientCA != `` {
+		if data, err := os.ReadFile(server.TLSClientCA); err == nil {
+			clientCAs = x509.NewCertPool()
+
+			if !clientCAs.AppendCertsFromPEM(data) {
+				return fmt.Errorf("tls: no certificates found in %s", server.TLSClientCA)
+			}
+		} else {
+			return fmt.Errorf("tls: %v", err)
+		}
+	}
+
+	server.tls.lock.Lock()
+	defer server.tls.lock.Unlock()
+
+	server.tls.certificate = &certificate
+	server.tls.clientCAs = clientCAs
+
+	return nil
+}
+
+// Returns the TLS configuration for the server's listener.  The certificate and client CAs
+// are looked up for each connection so that ReloadTLS takes effect without restarting.
+func (server *Server) tlsConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			server.tls.lock.RLock()
+			defer server.tls.lock.RUnlock()
+
+			var config = &tls.Config{
+				MinVersion:   tls.VersionTLS12,
+				Certificates: []tls.Certificate{*server.tls.certificate},
+			}
+
+			if server.tls.clientCAs != nil {
+				config.ClientCAs = server.tls.clientCAs
+				config.ClientAuth = tls.RequireAndVerifyClientCert
+			}
+
+			return config, nil
+		},
+	}
+}
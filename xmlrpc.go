@@ -0,0 +1,389 @@
+package procwatch
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const xmlrpcTimeFormat = `20060102T15:04:05`
+
+// An XmlRpcFault is returned to XML-RPC clients as a <fault> response.
+type XmlRpcFault struct {
+	Code   int
+	String string
+}
+
+func (fault *XmlRpcFault) Error() string {
+	return fmt.Sprintf("%d: %s", fault.Code, fault.String)
+}
+
+// Parses an XML-RPC <methodCall> document into the method name and its parameters.  Values
+// are decoded into the Go types string, int, bool, float64, time.Time, []byte, []any, and
+// map[string]any.
+func DecodeXmlRpcCall(r io.Reader) (string, []any, error) {
+	var decoder = xml.NewDecoder(r)
+	var method string
+	var params = make([]any, 0)
+
+	for {
+		tok, err := decoder.Token()
+
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return ``, nil, err
+		}
+
+		if start, ok := tok.(xml.StartElement); ok {
+			switch start.Name.Local {
+			case `methodName`:
+				if err := decoder.DecodeElement(&method, &start); err != nil {
+					return ``, nil, err
+				}
+
+				method = strings.TrimSpace(method)
+
+			case `value`:
+				if value, err := decodeXmlRpcValue(decoder); err == nil {
+					params = append(params, value)
+				} else {
+					return ``, nil, err
+				}
+			}
+		}
+	}
+
+	if method == `` {
+		return ``, nil, fmt.Errorf("missing methodName")
+	}
+
+	return method, params, nil
+}
+
+// decodes the contents of a <value> element, consuming tokens up to and including </value>
+func decodeXmlRpcValue(decoder *xml.Decoder) (any, error) {
+	var text strings.Builder
+
+	for {
+		tok, err := decoder.Token()
+
+		if err != nil {
+			return nil, err
+		}
+
+		switch t := tok.(type) {
+		case xml.CharData:
+			text.Write(t)
+
+		case xml.EndElement:
+			// a value with no type element is a string
+			return text.String(), nil
+
+		case xml.StartElement:
+			var value, err = decodeXmlRpcTyped(decoder, t)
+
+			if err != nil {
+				return nil, err
+			}
+
+			if err := decoder.Skip(); err != nil {
+				return nil, err
+			}
+
+			return value, nil
+		}
+	}
+}
+
+func decodeXmlRpcTyped(decoder *xml.Decoder, start xml.StartElement) (any, error) {
+	switch start.Name.Local {
+	case `struct`:
+		var out = make(map[string]any)
+
+		for {
+			tok, err := decoder.Token()
+
+			if err != nil {
+				return nil, err
+			}
+
+			switch t := tok.(type) {
+			case xml.EndElement:
+				return out, nil
+			case xml.StartElement:
+				if t.Name.Local != `member` {
+					return nil, fmt.Errorf("unexpected <%s> in struct", t.Name.Local)
+				}
+
+				if name, value, err := decodeXmlRpcMember(decoder); err == nil {
+					out[name] = value
+				} else {
+					return nil, err
+				}
+			}
+		}
+
+	case `array`:
+		var out = make([]any, 0)
+
+		for {
+			tok, err := decoder.Token()
+
+			if err != nil {
+				return nil, err
+			}
+
+			switch t := tok.(type) {
+			case xml.EndElement:
+				if t.Name.Local == `array` {
+					return out, nil
+				}
+			case xml.StartElement:
+				if t.Name.Local == `value` {
+					if value, err := decodeXmlRpcValue(decoder); err == nil {
+						out = append(out, value)
+					} else {
+						return nil, err
+					}
+				}
+			}
+		}
+
+	case `nil`:
+		return nil, decoder.Skip()
+	}
+
+	var text string
+
+	if err := decoder.DecodeElement(&text, &start); err != nil {
+		return nil, err
+	}
+
+	switch start.Name.Local {
+	case `int`, `i4`, `i8`:
+		return strconv.Atoi(strings.TrimSpace(text))
+	case `boolean`:
+		return strings.TrimSpace(text) == `1`, nil
+	case `double`:
+		return strconv.ParseFloat(strings.TrimSpace(text), 64)
+	case `dateTime.iso8601`:
+		return time.Parse(xmlrpcTimeFormat, strings.TrimSpace(text))
+	case `base64`:
+		return base64.StdEncoding.DecodeString(strings.TrimSpace(text))
+	case `string`:
+		return text, nil
+	default:
+		return nil, fmt.Errorf("unsupported type <%s>", start.Name.Local)
+	}
+}
+
+func decodeXmlRpcMember(decoder *xml.Decoder) (string, any, error) {
+	var name string
+	var value any
+
+	for {
+		tok, err := decoder.Token()
+
+		if err != nil {
+			return ``, nil, err
+		}
+
+		switch t := tok.(type) {
+		case xml.EndElement:
+			return name, value, nil
+		case xml.StartElement:
+			switch t.Name.Local {
+			case `name`:
+				if err := decoder.DecodeElement(&name, &t); err != nil {
+					return ``, nil, err
+				}
+			case `value`:
+				if v, err := decodeXmlRpcValue(decoder); err == nil {
+					value = v
+				} else {
+					return ``, nil, err
+				}
+			default:
+				return ``, nil, fmt.Errorf("unexpected <%s> in member", t.Name.Local)
+			}
+		}
+	}
+}
+
+// Parses an XML-RPC <methodResponse> document.  If the response is a fault, it is returned
+// as an *XmlRpcFault error.
+func DecodeXmlRpcResponse(r io.Reader) (any, error) {
+	var decoder = xml.NewDecoder(r)
+	var isFault bool
+
+	for {
+		tok, err := decoder.Token()
+
+		if err == io.EOF {
+			return nil, fmt.Errorf("missing response value")
+		} else if err != nil {
+			return nil, err
+		}
+
+		if start, ok := tok.(xml.StartElement); ok {
+			switch start.Name.Local {
+			case `fault`:
+				isFault = true
+
+			case `value`:
+				var value, err = decodeXmlRpcValue(decoder)
+
+				if err != nil {
+					return nil, err
+				} else if isFault {
+					var fault = new(XmlRpcFault)
+
+					if f, ok := value.(map[string]any); ok {
+						fault.Code, _ = f[`faultCode`].(int)
+						fault.String, _ = f[`faultString`].(string)
+					}
+
+					return nil, fault
+				}
+
+				return value, nil
+			}
+		}
+	}
+}
+
+// Encodes the given method name and parameters as an XML-RPC <methodCall>.
+func EncodeXmlRpcCall(w io.Writer, method string, params ...any) error {
+	var buf bytes.Buffer
+
+	buf.WriteString(`<?xml version="1.0"?>` + "\n")
+	buf.WriteString(`<methodCall><methodName>`)
+	xml.EscapeText(&buf, []byte(method))
+	buf.WriteString(`</methodName><params>`)
+
+	for _, param := range params {
+		buf.WriteString(`<param><value>`)
+		encodeXmlRpcValue(&buf, param)
+		buf.WriteString(`</value></param>`)
+	}
+
+	buf.WriteString(`</params></methodCall>` + "\n")
+
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// Encodes the given value as an XML-RPC <methodResponse>.  If the value is an *XmlRpcFault,
+// a fault response is produced instead.
+func EncodeXmlRpcResponse(w io.Writer, value any) error {
+	var buf bytes.Buffer
+
+	buf.WriteString(`<?xml version="1.0"?>` + "\n")
+	buf.WriteString(`<methodResponse>`)
+
+	if fault, ok := value.(*XmlRpcFault); ok {
+		buf.WriteString(`<fault><value>`)
+		encodeXmlRpcValue(&buf, map[string]any{
+			`faultCode`:   fault.Code,
+			`faultString`: fault.String,
+		})
+		buf.WriteString(`</value></fault>`)
+	} else {
+		buf.WriteString(`<params><param><value>`)
+		encodeXmlRpcValue(&buf, value)
+		buf.WriteString(`</value></param></params>`)
+	}
+
+	buf.WriteString(`</methodResponse>` + "\n")
+
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+func encodeXmlRpcValue(buf *bytes.Buffer, value any) {
+	switch v := value.(type) {
+	case nil:
+		// <nil/> is an extension that not every client understands
+		buf.WriteString(`<string></string>`)
+	case bool:
+		if v {
+			buf.WriteString(`<boolean>1</boolean>`)
+		} else {
+			buf.WriteString(`<boolean>0</boolean>`)
+		}
+	case int, int8, int16, int32, int64:
+		if n := reflect.ValueOf(v).Int(); n >= math.MinInt32 && n <= math.MaxInt32 {
+			fmt.Fprintf(buf, "<int>%d</int>", n)
+		} else {
+			fmt.Fprintf(buf, "<i8>%d</i8>", n)
+		}
+	case uint, uint8, uint16, uint32, uint64:
+		if n := reflect.ValueOf(v).Uint(); n <= math.MaxInt32 {
+			fmt.Fprintf(buf, "<int>%d</int>", n)
+		} else {
+			fmt.Fprintf(buf, "<i8>%d</i8>", n)
+		}
+	case float32, float64:
+		fmt.Fprintf(buf, "<double>%v</double>", v)
+	case string:
+		buf.WriteString(`<string>`)
+		xml.EscapeText(buf, []byte(v))
+		buf.WriteString(`</string>`)
+	case []byte:
+		buf.WriteString(`<base64>` + base64.StdEncoding.EncodeToString(v) + `</base64>`)
+	case time.Time:
+		buf.WriteString(`<dateTime.iso8601>` + v.Format(xmlrpcTimeFormat) + `</dateTime.iso8601>`)
+	case *XmlRpcFault:
+		encodeXmlRpcValue(buf, map[string]any{
+			`faultCode`:   v.Code,
+			`faultString`: v.String,
+		})
+	default:
+		var rv = reflect.ValueOf(value)
+
+		switch rv.Kind() {
+		case reflect.Slice, reflect.Array:
+			buf.WriteString(`<array><data>`)
+
+			for i := 0; i < rv.Len(); i++ {
+				buf.WriteString(`<value>`)
+				encodeXmlRpcValue(buf, rv.Index(i).Interface())
+				buf.WriteString(`</value>`)
+			}
+
+			buf.WriteString(`</data></array>`)
+
+		case reflect.Map:
+			var keys = make([]string, 0, rv.Len())
+
+			for _, key := range rv.MapKeys() {
+				keys = append(keys, fmt.Sprintf("%v", key.Interface()))
+			}
+
+			sort.Strings(keys)
+			buf.WriteString(`<struct>`)
+
+			for _, key := range keys {
+				buf.WriteString(`<member><name>`)
+				xml.EscapeText(buf, []byte(key))
+				buf.WriteString(`</name><value>`)
+				encodeXmlRpcValue(buf, rv.MapIndex(reflect.ValueOf(key).Convert(rv.Type().Key())).Interface())
+				buf.WriteString(`</value></member>`)
+			}
+
+			buf.WriteString(`</struct>`)
+
+		default:
+			encodeXmlRpcValue(buf, fmt.Sprintf("%v", value))
+		}
+	}
+}